@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// RoleApprover may approve or reject repository requests
+	RoleApprover = "approver"
+	// RoleTrusted may create public repositories without approval
+	RoleTrusted = "trusted"
+	// ApprovalKeyPrefix is the Redis key prefix for requests awaiting approval
+	ApprovalKeyPrefix = "slashviberepo:approval"
+	// ActionApproveRepo is the action ID of the Approve button
+	ActionApproveRepo = "approve_repo_request"
+	// ActionRejectRepo is the action ID of the Reject button
+	ActionRejectRepo = "reject_repo_request"
+)
+
+// Decisions recorded on approvalsTotal
+const (
+	ApprovalRequested = "requested"
+	ApprovalApproved  = "approved"
+	ApprovalRejected  = "rejected"
+)
+
+// BlockActionsPayload represents an incoming block_actions interaction (e.g. a button click) from Redis
+type BlockActionsPayload struct {
+	Type        string `json:"type"`
+	Token       string `json:"token"`
+	APIAppID    string `json:"api_app_id"`
+	ResponseURL string `json:"response_url"`
+	Team        struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Actions []BlockAction `json:"actions"`
+}
+
+// BlockAction is a single action within a block_actions payload
+type BlockAction struct {
+	ActionID string `json:"action_id"`
+	BlockID  string `json:"block_id"`
+	Value    string `json:"value"`
+}
+
+// MessagePoster posts Slack messages (satisfied by *slack.Client)
+type MessagePoster interface {
+	PostMessageContext(ctx context.Context, channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// RepoRequest is a fully built repository request, held in Redis while it awaits approval
+// Its ID is the correlation ID of the Poppit command
+type RepoRequest struct {
+	Repo        string        `json:"repo"`
+	Description string        `json:"description"`
+	Visibility  string        `json:"visibility"`
+	HasIssue    bool          `json:"has_issue"`
+	RequestedBy string        `json:"requested_by"`
+	RequestedAt time.Time     `json:"requested_at"`
+	Command     PoppitCommand `json:"command"`
+}
+
+// ID returns the request ID used in approval buttons and Redis keys
+func (r *RepoRequest) ID() string {
+	return r.Command.CorrelationID
+}
+
+// Org returns the GitHub organization the repository is requested in
+func (r *RepoRequest) Org() string {
+	org, _, _ := strings.Cut(r.Repo, "/")
+	return org
+}
+
+func approvalKey(requestID string) string {
+	return fmt.Sprintf("%s:%s", ApprovalKeyPrefix, requestID)
+}
+
+// needsApproval reports whether a request has to be approved before it is queued
+// Non-public repositories always need approval; public ones unless the requester is trusted
+func needsApproval(ctx context.Context, logger *Logger, authorizer Authorizer, config *Config, request *RepoRequest) bool {
+	if config.ApproversChannel == "" {
+		return false
+	}
+	if request.Visibility != "public" {
+		return true
+	}
+
+	trusted, err := authorizer.Authorize(ctx, request.RequestedBy, request.Org(), RoleTrusted)
+	if err != nil {
+		logger.Warn("Failed to check whether user is trusted, requiring approval", "user_id", request.RequestedBy, "error", err)
+		return true
+	}
+	return !trusted
+}
+
+// requestApproval stores the request and posts Approve/Reject buttons to the approvers channel
+func (s *Service) requestApproval(ctx context.Context, config *Config, request *RepoRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return &HandlerError{Stage: StageRequestApproval, Err: fmt.Errorf("failed to marshal repo request: %w", err)}
+	}
+	if err := s.Redis.Set(ctx, approvalKey(request.ID()), data, config.ApprovalTTL).Err(); err != nil {
+		return &HandlerError{Stage: StageRequestApproval, Err: fmt.Errorf("failed to store repo request: %w", err), Retryable: true}
+	}
+
+	_, _, err = s.Poster.PostMessageContext(ctx, config.ApproversChannel,
+		slack.MsgOptionText(fmt.Sprintf("<@%s> requested %s repository %s", request.RequestedBy, request.Visibility, request.Repo), false),
+		slack.MsgOptionBlocks(approvalRequestBlocks(request, config.ApprovalTTL)...),
+	)
+	if err != nil {
+		slackAPIErrorsTotal.WithLabelValues(SlackMethodChatPostMessage).Inc()
+		// Without the message nobody can approve it, so do not leave it behind
+		if delErr := s.Redis.Del(ctx, approvalKey(request.ID())).Err(); delErr != nil {
+			s.Logger.Error("Failed to delete repo request", "repo", request.Repo, "request_id", request.ID(), "error", delErr)
+		}
+		return &HandlerError{Stage: StageRequestApproval, Err: fmt.Errorf("failed to post approval request: %w", err), Retryable: true}
+	}
+
+	approvalsTotal.WithLabelValues(ApprovalRequested).Inc()
+	s.Logger.Info("Requested approval", "repo", request.Repo, "request_id", request.ID(), "user_id", request.RequestedBy, "channel", config.ApproversChannel)
+
+	text := fmt.Sprintf("⏳ <@%s> requested *%s*. It will be created once an approver approves it.", request.RequestedBy, request.Repo)
+	s.notify(ctx, config.SlackChannelNewRepo, text)
+	return nil
+}
+
+// approvalRequestBlocks builds the approval request message with Approve and Reject buttons
+func approvalRequestBlocks(request *RepoRequest, ttl time.Duration) []slack.Block {
+	text := fmt.Sprintf("*<@%s>* requested a new *%s* repository: *%s*", request.RequestedBy, request.Visibility, request.Repo)
+	if request.Description != "" {
+		text = fmt.Sprintf("%s\n>%s", text, request.Description)
+	}
+
+	approve := slack.NewButtonBlockElement(ActionApproveRepo, request.ID(),
+		slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false)).WithStyle(slack.StylePrimary)
+	reject := slack.NewButtonBlockElement(ActionRejectRepo, request.ID(),
+		slack.NewTextBlockObject(slack.PlainTextType, "Reject", false, false)).WithStyle(slack.StyleDanger)
+
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("repo-approval", approve, reject),
+		slack.NewContextBlock("repo-approval-expiry",
+			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("This request expires after %s.", formatTTL(ttl)), false, false)),
+	}
+}
+
+// handleApprovalAction approves or rejects a held repository request
+func (s *Service) handleApprovalAction(ctx context.Context, payload *BlockActionsPayload, action *BlockAction) error {
+	config := s.Configs.Get()
+	approverID := payload.User.ID
+	key := approvalKey(action.Value)
+	data, err := s.Redis.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		respondEphemeral(ctx, s.Logger, payload.ResponseURL, "This request is no longer pending. It was already handled or has expired.")
+		return nil
+	}
+	if err != nil {
+		return &HandlerError{Stage: StageHandleBlockAction, Err: fmt.Errorf("failed to load repo request: %w", err), Retryable: true}
+	}
+
+	var request RepoRequest
+	if err := json.Unmarshal([]byte(data), &request); err != nil {
+		return &HandlerError{Stage: StageHandleBlockAction, Err: fmt.Errorf("failed to unmarshal repo request: %w", err)}
+	}
+
+	// Approvers may be set per organization, so the request is loaded first
+	if !authorizeUser(ctx, s.Logger, s.Authorizer, approverID, request.Org(), RoleApprover, AuthStageApproval) {
+		respondEphemeral(ctx, s.Logger, payload.ResponseURL, fmt.Sprintf("You are not allowed to approve or reject repository requests in %s.", request.Org()))
+		return nil
+	}
+	if action.ActionID == ActionApproveRepo && approverID == request.RequestedBy {
+		respondEphemeral(ctx, s.Logger, payload.ResponseURL, "You cannot approve your own request.")
+		return nil
+	}
+
+	// Only the first decision counts; a missing key means another approver got there first
+	// The remaining TTL is read in the same transaction so a restored request still expires on time
+	pipe := s.Redis.TxPipeline()
+	ttl := pipe.PTTL(ctx, key)
+	del := pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return &HandlerError{Stage: StageHandleBlockAction, Err: fmt.Errorf("failed to claim repo request: %w", err), Retryable: true}
+	}
+	if del.Val() == 0 {
+		respondEphemeral(ctx, s.Logger, payload.ResponseURL, "This request is no longer pending. It was already handled or has expired.")
+		return nil
+	}
+	expiresAt := time.Now().Add(ttl.Val())
+
+	// Put the request back so it can be approved again, unless it has expired in the meantime
+	restore := func() {
+		// A request stored without a TTL (PTTL returns a negative value) is put back without one
+		var remaining time.Duration
+		if ttl.Val() > 0 {
+			remaining = time.Until(expiresAt)
+			if remaining <= 0 {
+				return
+			}
+		}
+		if restoreErr := s.Redis.Set(ctx, key, data, remaining).Err(); restoreErr != nil {
+			s.Logger.Error("Failed to restore repo request", "repo", request.Repo, "request_id", request.ID(), "error", restoreErr)
+		}
+	}
+
+	switch action.ActionID {
+	case ActionApproveRepo:
+		// The repository may have been created while the request was held; if GitHub cannot be reached, let Poppit find out
+		exists, err := s.RepoChecker.RepoExists(ctx, request.Repo)
+		if err != nil {
+			s.Logger.Warn("Could not check whether repository exists, continuing", "repo", request.Repo, "error", err)
+		} else if exists {
+			approvalsTotal.WithLabelValues(ApprovalRejected).Inc()
+			s.Logger.Info("Repository already exists, rejecting repo request", "repo", request.Repo, "request_id", request.ID(), "user_id", approverID)
+			respondEphemeral(ctx, s.Logger, payload.ResponseURL, fmt.Sprintf("%s already exists, so the request has been rejected.", request.Repo))
+			text := fmt.Sprintf("❌ <@%s>'s request for *%s* was rejected because the repository already exists.", request.RequestedBy, request.Repo)
+			replaceOriginal(ctx, s.Logger, payload.ResponseURL, text)
+			s.notify(ctx, config.SlackChannelNewRepo, text)
+			return nil
+		}
+
+		// Held requests only count against the rate limits once approved
+		exceeded, err := reserveRateLimit(ctx, s.Redis, config, request.Org(), request.RequestedBy, request.ID())
+		if err != nil {
+			restore()
+			return &HandlerError{Stage: StageRateLimit, Err: err, Retryable: true}
+		}
+		if exceeded != nil {
+			restore()
+			rateLimitRejectionsTotal.WithLabelValues(exceeded.Scope).Inc()
+			s.Logger.Warn("Rate limit exceeded, leaving request pending", "user_id", request.RequestedBy, "repo", request.Repo, "scope", exceeded.Scope, "limit", exceeded.Limit.String(), "retry_after", exceeded.RetryAfter.String())
+			respondEphemeral(ctx, s.Logger, payload.ResponseURL, fmt.Sprintf("This request cannot be approved yet and is still pending. %s", rateLimitMessage(exceeded, request.RequestedBy)))
+			return nil
+		}
+
+		if err := s.queueRepoRequest(ctx, config, &request); err != nil {
+			restore()
+			if releaseErr := releaseRateLimit(ctx, s.Redis, config, request.Org(), request.RequestedBy, request.ID()); releaseErr != nil {
+				s.Logger.Error("Failed to release rate limit", "user_id", request.RequestedBy, "repo", request.Repo, "error", releaseErr)
+			}
+			return err
+		}
+		approvalsTotal.WithLabelValues(ApprovalApproved).Inc()
+		s.Logger.Info("Repo request approved", "repo", request.Repo, "request_id", request.ID(), "user_id", approverID)
+		replaceOriginal(ctx, s.Logger, payload.ResponseURL, fmt.Sprintf("✅ <@%s>'s request for *%s* was approved by <@%s>.", request.RequestedBy, request.Repo, approverID))
+
+	case ActionRejectRepo:
+		approvalsTotal.WithLabelValues(ApprovalRejected).Inc()
+		s.Logger.Info("Repo request rejected", "repo", request.Repo, "request_id", request.ID(), "user_id", approverID)
+		text := fmt.Sprintf("❌ <@%s>'s request for *%s* was rejected by <@%s>.", request.RequestedBy, request.Repo, approverID)
+		replaceOriginal(ctx, s.Logger, payload.ResponseURL, text)
+		s.notify(ctx, config.SlackChannelNewRepo, text)
+	}
+	return nil
+}
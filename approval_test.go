@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/slack-go/slack"
+)
+
+// TestNeedsApproval tests which requests are held for an approver
+func TestNeedsApproval(t *testing.T) {
+	trusted := &fakeAuthorizer{allowed: map[string]bool{"U_TRUSTED": true}}
+	enabled := &Config{ApproversChannel: "C_APPROVERS"}
+
+	tests := []struct {
+		name       string
+		config     *Config
+		authorizer Authorizer
+		visibility string
+		user       string
+		want       bool
+	}{
+		{"Disabled", &Config{}, trusted, "private", "U_OTHER", false},
+		{"PublicTrusted", enabled, trusted, "public", "U_TRUSTED", false},
+		{"PublicUntrusted", enabled, trusted, "public", "U_OTHER", true},
+		{"PrivateTrusted", enabled, trusted, "private", "U_TRUSTED", true},
+		{"InternalTrusted", enabled, trusted, "internal", "U_TRUSTED", true},
+		{"PublicTrustedRoleOpen", enabled, &fakeAuthorizer{}, "public", "U_OTHER", false},
+		{"AuthorizerError", enabled, &fakeAuthorizer{err: errors.New("slack unavailable")}, "public", "U_TRUSTED", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &RepoRequest{Visibility: tt.visibility, RequestedBy: tt.user}
+			if got := needsApproval(context.Background(), NewLogger("error"), tt.authorizer, tt.config, request); got != tt.want {
+				t.Errorf("needsApproval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestApprovalRequestBlocks tests that both buttons carry the request ID
+func TestApprovalRequestBlocks(t *testing.T) {
+	request := &RepoRequest{
+		Repo:        "org/secret",
+		Description: "Internal tooling",
+		Visibility:  "private",
+		RequestedBy: "U123",
+		Command:     PoppitCommand{CorrelationID: "abc123"},
+	}
+
+	data, err := json.Marshal(approvalRequestBlocks(request, 24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to marshal blocks: %v", err)
+	}
+	body := string(data)
+	for _, want := range []string{"org/secret", "U123", "Internal tooling", ActionApproveRepo, ActionRejectRepo, `"value":"abc123"`, "expires after 24h."} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected approval blocks to contain %q, got: %s", want, body)
+		}
+	}
+}
+
+// TestCommandRouterBlockActions tests that block actions reach the command owning the action ID
+func TestCommandRouterBlockActions(t *testing.T) {
+	router := NewCommandRouter(NewLogger("error"), &fakeViewResponder{}, NewPayloadVerifier("", []string{"T1"}, nil))
+
+	var handled []string
+	err := router.Register(&SlashCommand{
+		Name:          "/alpha",
+		HandleCommand: func(ctx context.Context, cmd *SlashCommandPayload) {},
+		ActionIDs:     []string{"alpha_approve"},
+		HandleBlockAction: func(ctx context.Context, payload *BlockActionsPayload, action *BlockAction) error {
+			if action.Value == "fail" {
+				return errors.New("redis unavailable")
+			}
+			handled = append(handled, action.ActionID+":"+action.Value+":"+payload.User.ID)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register command: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, payload := range []string{
+		`{"type":"block_actions","team":{"id":"T1"},"user":{"id":"U1"},"actions":[{"action_id":"alpha_approve","value":"r1"},{"action_id":"other_button","value":"r2"}]}`,
+		`{"type":"block_actions","team":{"id":"T9"},"user":{"id":"U1"},"actions":[{"action_id":"alpha_approve","value":"forged"}]}`,
+	} {
+		if err := router.HandleBlockActions(ctx, payload); err != nil {
+			t.Errorf("HandleBlockActions(%s) returned unexpected error: %v", payload, err)
+		}
+	}
+
+	if len(handled) != 1 || handled[0] != "alpha_approve:r1:U1" {
+		t.Errorf("Unexpected actions handled: %v", handled)
+	}
+
+	err = router.HandleBlockActions(ctx, `{"team":{"id":"T1"},"actions":[{"action_id":"alpha_approve","value":"fail"}]}`)
+	var handlerErr *HandlerError
+	if !errors.As(err, &handlerErr) || handlerErr.Stage != StageHandleBlockAction || !handlerErr.Retryable {
+		t.Errorf("Expected a retryable %s error, got %v", StageHandleBlockAction, err)
+	}
+
+	err = router.HandleBlockActions(ctx, `not json`)
+	if !errors.As(err, &handlerErr) || handlerErr.Stage != StageParseBlockActions {
+		t.Errorf("Expected a %s error, got %v", StageParseBlockActions, err)
+	}
+
+	// Action IDs need a handler and cannot be registered twice
+	noop := func(ctx context.Context, cmd *SlashCommandPayload) {}
+	if err := router.Register(&SlashCommand{Name: "/beta", HandleCommand: noop, ActionIDs: []string{"beta_approve"}}); err == nil {
+		t.Error("Expected an error for action IDs without a handler")
+	}
+	duplicate := &SlashCommand{
+		Name:              "/gamma",
+		HandleCommand:     noop,
+		ActionIDs:         []string{"alpha_approve"},
+		HandleBlockAction: func(ctx context.Context, payload *BlockActionsPayload, action *BlockAction) error { return nil },
+	}
+	if err := router.Register(duplicate); err == nil {
+		t.Error("Expected an error for a duplicate action ID")
+	}
+}
+
+// TestHandleApprovalActionNotApprover tests that non-approvers are told so and the request stays pending
+func TestHandleApprovalActionNotApprover(t *testing.T) {
+	service, server := newTestService(t, &Config{})
+	// U_APPROVER is an approver, but not in secret-org
+	service.Authorizer = &fakeAuthorizer{allowed: map[string]bool{"U_APPROVER": true}, orgs: map[string]bool{"my-org": true}}
+	recorder := newResponseRecorder(t)
+
+	request := &RepoRequest{Repo: "secret-org/tool", Visibility: "private", RequestedBy: "U123", Command: PoppitCommand{CorrelationID: "abc123"}}
+	storeTestRequest(t, server, request)
+
+	payload := &BlockActionsPayload{ResponseURL: recorder.URL}
+	payload.User.ID = "U_APPROVER"
+	action := &BlockAction{ActionID: ActionApproveRepo, Value: "abc123"}
+
+	if err := service.handleApprovalAction(context.Background(), payload, action); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	responses := recorder.Messages()
+	if len(responses) != 1 || !strings.Contains(responses[0].Text, "not allowed") || !strings.Contains(responses[0].Text, "secret-org") || responses[0].ResponseType != slack.ResponseTypeEphemeral {
+		t.Errorf("Expected one ephemeral denial naming the organization, got %+v", responses)
+	}
+	if !server.Exists(approvalKey(request.ID())) {
+		t.Error("Expected the request to stay pending")
+	}
+}
+
+// approvalTestConfig enables approvals for the approval workflow tests
+func approvalTestConfig() *Config {
+	return &Config{
+		GithubOrg:           "my-org",
+		ApproversChannel:    "C_APPROVERS",
+		ApprovalTTL:         time.Hour,
+		SlackChannelNewRepo: "#new-repo",
+	}
+}
+
+// storeTestRequest holds request for approval as requestApproval would
+func storeTestRequest(t *testing.T, server *miniredis.Miniredis, request *RepoRequest) {
+	t.Helper()
+	data, err := json.Marshal(request)
+	if err != nil {
+		t.Fatalf("Failed to marshal repo request: %v", err)
+	}
+	server.Set(approvalKey(request.ID()), string(data))
+}
+
+// TestRequestApproval tests that a held request is stored, posted to the approvers and announced
+func TestRequestApproval(t *testing.T) {
+	config := approvalTestConfig()
+	service, server := newTestService(t, config)
+	poster := service.Poster.(*fakeMessagePoster)
+	notifier := service.Notifier.(*fakeNotifier)
+	ctx := context.Background()
+
+	request := &RepoRequest{Repo: "my-org/tool", Visibility: "private", RequestedBy: "U1", Command: PoppitCommand{CorrelationID: "abc123"}}
+	if err := service.requestApproval(ctx, config, request); err != nil {
+		t.Fatalf("requestApproval() failed: %v", err)
+	}
+	if ttl := server.TTL(approvalKey("abc123")); ttl != time.Hour {
+		t.Errorf("Expected the request to be held for APPROVAL_TTL, got %s", ttl)
+	}
+	if len(poster.channels) != 1 || poster.channels[0] != "C_APPROVERS" {
+		t.Errorf("Expected one message in the approvers channel, got %v", poster.channels)
+	}
+	if messages := notifier.Messages(); len(messages) != 1 || messages[0].Channel != "#new-repo" || !strings.Contains(messages[0].Text, "once an approver approves it") {
+		t.Errorf("Expected the requester to be told, got %+v", messages)
+	}
+
+	// Without the approval message nobody could approve it, so the request is not left behind
+	poster.err = errors.New("channel_not_found")
+	request.Command.CorrelationID = "def456"
+	err := service.requestApproval(ctx, config, request)
+	var handlerErr *HandlerError
+	if !errors.As(err, &handlerErr) || handlerErr.Stage != StageRequestApproval || !handlerErr.Retryable {
+		t.Fatalf("Expected a retryable %s error, got %v", StageRequestApproval, err)
+	}
+	if server.Exists(approvalKey("def456")) {
+		t.Error("Expected the request to be deleted when the post fails")
+	}
+	if got := len(notifier.Messages()); got != 1 {
+		t.Errorf("Expected no announcement for a failed request, got %d messages", got)
+	}
+}
+
+// TestHandleApprovalAction tests approving, rejecting and the cases where a click does nothing
+func TestHandleApprovalAction(t *testing.T) {
+	request := &RepoRequest{
+		Repo:        "my-org/tool",
+		Visibility:  "private",
+		RequestedBy: "U_REQUESTER",
+		Command:     PoppitCommand{Repo: "my-org/tool", Commands: []string{"gh repo create my-org/tool --private"}, CorrelationID: "abc123"},
+	}
+	click := func(t *testing.T, service *Service, user, actionID string) (*responseRecorder, error) {
+		t.Helper()
+		recorder := newResponseRecorder(t)
+		payload := &BlockActionsPayload{ResponseURL: recorder.URL}
+		payload.User.ID = user
+		err := service.handleApprovalAction(context.Background(), payload, &BlockAction{ActionID: actionID, Value: request.ID()})
+		return recorder, err
+	}
+
+	t.Run("Approve", func(t *testing.T) {
+		service, server := newTestService(t, approvalTestConfig())
+		storeTestRequest(t, server, request)
+
+		recorder, err := click(t, service, "U_APPROVER", ActionApproveRepo)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if commands := service.Queue.(*fakeQueue).Commands(); len(commands) != 1 || commands[0].CorrelationID != "abc123" {
+			t.Errorf("Expected the held command to be queued, got %+v", commands)
+		}
+		if server.Exists(approvalKey("abc123")) {
+			t.Error("Expected the request to be deleted")
+		}
+		if messages := recorder.Messages(); len(messages) != 1 || !messages[0].ReplaceOriginal || !strings.Contains(messages[0].Text, "approved by <@U_APPROVER>") {
+			t.Errorf("Expected the approval message to be replaced, got %+v", messages)
+		}
+		if messages := service.Notifier.(*fakeNotifier).Messages(); len(messages) != 1 || !strings.Contains(messages[0].Text, "New repository creation initiated") {
+			t.Errorf("Expected the usual confirmation, got %+v", messages)
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		service, server := newTestService(t, approvalTestConfig())
+		storeTestRequest(t, server, request)
+
+		recorder, err := click(t, service, "U_APPROVER", ActionRejectRepo)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if commands := service.Queue.(*fakeQueue).Commands(); len(commands) != 0 {
+			t.Errorf("Expected nothing to be queued, got %+v", commands)
+		}
+		if server.Exists(approvalKey("abc123")) {
+			t.Error("Expected the request to be deleted")
+		}
+		if messages := recorder.Messages(); len(messages) != 1 || !messages[0].ReplaceOriginal || !strings.Contains(messages[0].Text, "rejected by <@U_APPROVER>") {
+			t.Errorf("Expected the approval message to be replaced, got %+v", messages)
+		}
+		if messages := service.Notifier.(*fakeNotifier).Messages(); len(messages) != 1 || messages[0].Channel != "#new-repo" || !strings.Contains(messages[0].Text, "rejected") {
+			t.Errorf("Expected the requester to be told, got %+v", messages)
+		}
+	})
+
+	t.Run("RepoExists", func(t *testing.T) {
+		service, server := newTestService(t, approvalTestConfig())
+		service.RepoChecker = &fakeRepoChecker{existing: map[string]bool{"my-org/tool": true}}
+		storeTestRequest(t, server, request)
+
+		recorder, err := click(t, service, "U_APPROVER", ActionApproveRepo)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if commands := service.Queue.(*fakeQueue).Commands(); len(commands) != 0 {
+			t.Errorf("Expected nothing to be queued, got %+v", commands)
+		}
+		if server.Exists(approvalKey("abc123")) {
+			t.Error("Expected the request to be deleted")
+		}
+		messages := recorder.Messages()
+		if len(messages) != 2 || messages[0].ResponseType != slack.ResponseTypeEphemeral || !strings.Contains(messages[0].Text, "my-org/tool already exists") {
+			t.Fatalf("Expected the approver to be told, got %+v", messages)
+		}
+		if !messages[1].ReplaceOriginal || !strings.Contains(messages[1].Text, "already exists") {
+			t.Errorf("Expected the approval message to be replaced, got %+v", messages[1])
+		}
+	})
+
+	t.Run("SelfApproval", func(t *testing.T) {
+		service, server := newTestService(t, approvalTestConfig())
+		storeTestRequest(t, server, request)
+
+		recorder, err := click(t, service, "U_REQUESTER", ActionApproveRepo)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if messages := recorder.Messages(); len(messages) != 1 || messages[0].ResponseType != slack.ResponseTypeEphemeral || !strings.Contains(messages[0].Text, "your own request") {
+			t.Errorf("Expected an ephemeral refusal, got %+v", messages)
+		}
+		if !server.Exists(approvalKey("abc123")) || len(service.Queue.(*fakeQueue).Commands()) != 0 {
+			t.Error("Expected the request to stay pending")
+		}
+	})
+
+	t.Run("NoLongerPending", func(t *testing.T) {
+		service, _ := newTestService(t, approvalTestConfig())
+
+		recorder, err := click(t, service, "U_APPROVER", ActionApproveRepo)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if messages := recorder.Messages(); len(messages) != 1 || !strings.Contains(messages[0].Text, "no longer pending") {
+			t.Errorf("Expected an ephemeral reply, got %+v", messages)
+		}
+	})
+
+	t.Run("QueueFailure", func(t *testing.T) {
+		service, server := newTestService(t, approvalTestConfig())
+		service.Queue.(*fakeQueue).err = errors.New("connection refused")
+		storeTestRequest(t, server, request)
+		server.SetTTL(approvalKey("abc123"), 30*time.Minute)
+
+		recorder, err := click(t, service, "U_APPROVER", ActionApproveRepo)
+		var handlerErr *HandlerError
+		if !errors.As(err, &handlerErr) || handlerErr.Stage != StageQueuePoppit {
+			t.Fatalf("Expected a %s error, got %v", StageQueuePoppit, err)
+		}
+		// The request is put back so it can be approved again, still expiring when it would have
+		if ttl := server.TTL(approvalKey("abc123")); ttl <= 29*time.Minute || ttl > 30*time.Minute {
+			t.Errorf("Expected the request to be restored with its remaining TTL, got %s", ttl)
+		}
+		if messages := recorder.Messages(); len(messages) != 0 {
+			t.Errorf("Expected the approval message to be left alone, got %+v", messages)
+		}
+	})
+
+	t.Run("ConcurrentApprovals", func(t *testing.T) {
+		service, server := newTestService(t, approvalTestConfig())
+		storeTestRequest(t, server, request)
+
+		recorders := make([]*responseRecorder, 2)
+		for i := range recorders {
+			recorders[i] = newResponseRecorder(t)
+		}
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for i, approver := range []string{"U_APPROVER_1", "U_APPROVER_2"} {
+			wg.Add(1)
+			go func(recorder *responseRecorder, approver string) {
+				defer wg.Done()
+				payload := &BlockActionsPayload{ResponseURL: recorder.URL}
+				payload.User.ID = approver
+				<-start
+				if err := service.handleApprovalAction(context.Background(), payload, &BlockAction{ActionID: ActionApproveRepo, Value: request.ID()}); err != nil {
+					t.Errorf("Unexpected error for %s: %v", approver, err)
+				}
+			}(recorders[i], approver)
+		}
+		close(start)
+		wg.Wait()
+
+		// Only the first decision counts; the other approver is told it was already handled
+		if commands := service.Queue.(*fakeQueue).Commands(); len(commands) != 1 {
+			t.Errorf("Expected the request to be queued once, got %d", len(commands))
+		}
+		var approved, stale int
+		for _, recorder := range recorders {
+			for _, msg := range recorder.Messages() {
+				switch {
+				case msg.ReplaceOriginal && strings.Contains(msg.Text, "approved"):
+					approved++
+				case strings.Contains(msg.Text, "no longer pending"):
+					stale++
+				}
+			}
+		}
+		if approved != 1 || stale != 1 {
+			t.Errorf("Expected one approval and one stale reply, got %d and %d", approved, stale)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// RoleCreator may create repositories with /new-repo
+	RoleCreator = "creator"
+	// UsergroupCacheTTL is how long Slack user group members are cached
+	UsergroupCacheTTL = 5 * time.Minute
+)
+
+// Stages recorded on authorizationDenialsTotal
+const (
+	AuthStageCommand        = "command"
+	AuthStageViewSubmission = "view_submission"
+	AuthStageApproval       = "approval"
+)
+
+// Authorizer decides whether a Slack user holds a role in a GitHub organization
+type Authorizer interface {
+	Authorize(ctx context.Context, userID, org, role string) (bool, error)
+}
+
+// UsergroupLister lists the members of a Slack user group (satisfied by *slack.Client)
+type UsergroupLister interface {
+	GetUserGroupMembersContext(ctx context.Context, userGroup string, options ...slack.GetUserGroupMembersOption) ([]string, error)
+}
+
+// RoleMembers lists who holds a role, by user ID and by Slack user group ID
+type RoleMembers struct {
+	Users      []string `yaml:"users"`
+	Usergroups []string `yaml:"usergroups"`
+}
+
+// Policy maps role names to their members, optionally overridden per GitHub organization
+// A role missing from the policy is open to everyone, except for the closedRoles
+type Policy struct {
+	Roles map[string]RoleMembers `yaml:"roles"`
+	Orgs  map[string]OrgPolicy   `yaml:"orgs"`
+}
+
+// OrgPolicy lists the roles that are held differently in one organization
+type OrgPolicy struct {
+	Roles map[string]RoleMembers `yaml:"roles"`
+}
+
+// closedRoles are held by nobody when a policy file does not define them, so a policy written
+// only for creators does not let everyone approve requests or skip approval
+var closedRoles = map[string]bool{RoleApprover: true, RoleTrusted: true}
+
+// roleMembers returns who holds role in org: the organization's own entry if it has one,
+// otherwise the top-level entry
+func (p *Policy) roleMembers(org, role string) (RoleMembers, bool) {
+	if members, ok := p.Orgs[org].Roles[role]; ok {
+		return members, true
+	}
+	members, ok := p.Roles[role]
+	return members, ok
+}
+
+// loadPolicy reads and parses the policy file at path
+// The file is YAML, like TEMPLATES_FILE and CONFIG_FILE; JSON policies are valid YAML and still load
+// Unlike those files an empty policy is an error, so a truncated file cannot open every role
+func loadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var policy Policy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+	return &policy, nil
+}
+
+type cachedUsergroup struct {
+	members   map[string]bool
+	fetchedAt time.Time
+}
+
+// PolicyAuthorizer authorizes users against a YAML policy file
+// The file is reloaded when its modification time changes; if the new version cannot
+// be loaded the previous policy stays in force
+type PolicyAuthorizer struct {
+	logger     *Logger
+	file       *reloadingFile[*Policy]
+	usergroups UsergroupLister
+	now        func() time.Time
+
+	mu     sync.Mutex
+	groups map[string]cachedUsergroup
+}
+
+// NewPolicyAuthorizer loads the policy at path; an empty path allows everyone every role
+func NewPolicyAuthorizer(logger *Logger, path string, usergroups UsergroupLister) (*PolicyAuthorizer, error) {
+	a := &PolicyAuthorizer{
+		logger:     logger,
+		usergroups: usergroups,
+		now:        time.Now,
+		groups:     make(map[string]cachedUsergroup),
+	}
+	if path == "" {
+		return a, nil
+	}
+
+	file, err := newReloadingFile(logger, "policy", path, loadPolicy)
+	if err != nil {
+		return nil, err
+	}
+	a.file = file
+	return a, nil
+}
+
+// Authorize reports whether userID holds role in org
+func (a *PolicyAuthorizer) Authorize(ctx context.Context, userID, org, role string) (bool, error) {
+	members, ok := a.currentPolicy().roleMembers(org, role)
+	if !ok {
+		return a.file == nil || !closedRoles[role], nil
+	}
+
+	for _, user := range members.Users {
+		if user == userID {
+			return true, nil
+		}
+	}
+	for _, group := range members.Usergroups {
+		inGroup, err := a.inUsergroup(ctx, group, userID)
+		if err != nil {
+			return false, err
+		}
+		if inGroup {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// currentPolicy reloads the policy file if it has changed and returns the policy in force
+// Without a policy file every role is open to everyone
+func (a *PolicyAuthorizer) currentPolicy() *Policy {
+	if a.file == nil {
+		return &Policy{}
+	}
+	return a.file.Get()
+}
+
+// inUsergroup reports whether userID is in the Slack user group, caching members for UsergroupCacheTTL
+func (a *PolicyAuthorizer) inUsergroup(ctx context.Context, group, userID string) (bool, error) {
+	a.mu.Lock()
+	cached, ok := a.groups[group]
+	a.mu.Unlock()
+	if ok && a.now().Sub(cached.fetchedAt) < UsergroupCacheTTL {
+		return cached.members[userID], nil
+	}
+
+	if a.usergroups == nil {
+		return false, fmt.Errorf("no Slack client to look up user group %s", group)
+	}
+	members, err := a.usergroups.GetUserGroupMembersContext(ctx, group)
+	if err != nil {
+		slackAPIErrorsTotal.WithLabelValues(SlackMethodUsergroupsUsersList).Inc()
+		return false, fmt.Errorf("failed to list members of user group %s: %w", group, err)
+	}
+
+	cached = cachedUsergroup{members: toSet(members), fetchedAt: a.now()}
+	a.mu.Lock()
+	a.groups[group] = cached
+	a.mu.Unlock()
+	return cached.members[userID], nil
+}
+
+// notAuthorizedMessage explains to a denied user why nothing happened
+func notAuthorizedMessage(orgs ...string) string {
+	return fmt.Sprintf("You are not allowed to create repositories in %s. Ask an admin to add you to the %s role.", strings.Join(orgs, " or "), RoleCreator)
+}
+
+// authorizeUser checks role in org for userID, logging and counting denials
+// Errors are treated as a denial so a Slack outage does not open the door
+func authorizeUser(ctx context.Context, logger *Logger, authorizer Authorizer, userID, org, role, stage string) bool {
+	allowed, err := authorizer.Authorize(ctx, userID, org, role)
+	if err != nil {
+		logger.Error("Failed to check authorization", "user_id", userID, "org", org, "role", role, "error", err)
+		allowed = false
+	}
+	if !allowed {
+		authorizationDenialsTotal.WithLabelValues(role, stage).Inc()
+		logger.Warn("User is not authorized", "user_id", userID, "org", org, "role", role, "stage", stage)
+	}
+	return allowed
+}
+
+// creatorOrgs returns the configured organizations userID may create repositories in
+// Errors count as a denial for that organization; a denial is only recorded if no organization is left
+func creatorOrgs(ctx context.Context, logger *Logger, authorizer Authorizer, config *Config, userID, stage string) []string {
+	var orgs []string
+	for _, org := range config.Orgs() {
+		allowed, err := authorizer.Authorize(ctx, userID, org, RoleCreator)
+		if err != nil {
+			logger.Error("Failed to check authorization", "user_id", userID, "org", org, "role", RoleCreator, "error", err)
+			continue
+		}
+		if allowed {
+			orgs = append(orgs, org)
+		}
+	}
+	if len(orgs) == 0 {
+		authorizationDenialsTotal.WithLabelValues(RoleCreator, stage).Inc()
+		logger.Warn("User is not authorized", "user_id", userID, "orgs", config.Orgs(), "role", RoleCreator, "stage", stage)
+	}
+	return orgs
+}
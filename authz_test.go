@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// fakeAuthorizer allows the users in allowed, or everyone if allowed is nil,
+// in the organizations in orgs, or every organization if orgs is nil
+type fakeAuthorizer struct {
+	allowed map[string]bool
+	orgs    map[string]bool
+	err     error
+}
+
+func (f *fakeAuthorizer) Authorize(ctx context.Context, userID, org, role string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	return (f.allowed == nil || f.allowed[userID]) && (f.orgs == nil || f.orgs[org]), nil
+}
+
+// fakeUsergroupLister returns fixed user group members and counts lookups
+type fakeUsergroupLister struct {
+	members map[string][]string
+	calls   int
+}
+
+func (f *fakeUsergroupLister) GetUserGroupMembersContext(ctx context.Context, userGroup string, options ...slack.GetUserGroupMembersOption) ([]string, error) {
+	f.calls++
+	members, ok := f.members[userGroup]
+	if !ok {
+		return nil, errors.New("no_such_subteam")
+	}
+	return members, nil
+}
+
+func writePolicy(t *testing.T, path, policy string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(policy), 0o600); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	// Set the modification time explicitly so rewrites within the same tick are noticed
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set policy modification time: %v", err)
+	}
+}
+
+// TestPolicyAuthorizerAuthorize tests user and user group membership and open roles
+func TestPolicyAuthorizerAuthorize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, `{
+		"roles":{"creator":{"users":["U1"],"usergroups":["S1"]},"approver":{"users":["U5"]}},
+		"orgs":{"secret-org":{"roles":{"creator":{"users":["U4"]}}}}
+	}`, time.Now())
+
+	groups := &fakeUsergroupLister{members: map[string][]string{"S1": {"U2"}}}
+	authorizer, err := NewPolicyAuthorizer(NewLogger("error"), path, groups)
+	if err != nil {
+		t.Fatalf("Failed to create authorizer: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		org    string
+		role   string
+		want   bool
+	}{
+		{"ListedUser", "U1", "my-org", RoleCreator, true},
+		{"UsergroupMember", "U2", "my-org", RoleCreator, true},
+		{"Stranger", "U3", "my-org", RoleCreator, false},
+		{"UnconfiguredRole", "U3", "my-org", "other", true},
+		{"UnconfiguredClosedRole", "U1", "my-org", RoleTrusted, false},
+		{"OrgOverrideListedUser", "U4", "secret-org", RoleCreator, true},
+		{"OrgOverrideReplacesTopLevel", "U1", "secret-org", RoleCreator, false},
+		{"OrgOverrideOnlyInItsOrg", "U4", "my-org", RoleCreator, false},
+		{"OrgWithoutRoleFallsBack", "U5", "secret-org", RoleApprover, true},
+		{"OrgWithoutRoleFallsBackStranger", "U3", "secret-org", RoleApprover, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := authorizer.Authorize(context.Background(), tt.userID, tt.org, tt.role)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Authorize(%s, %s, %s) = %v, want %v", tt.userID, tt.org, tt.role, got, tt.want)
+			}
+		})
+	}
+
+	if groups.calls != 1 {
+		t.Errorf("Expected user group members to be cached, got %d lookups", groups.calls)
+	}
+}
+
+// TestPolicyAuthorizerClosedRoles tests that approver and trusted are only open without a policy file
+func TestPolicyAuthorizerClosedRoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writePolicy(t, path, "roles:\n  creator:\n    users: [U1]\n", time.Now())
+	withPolicy, err := NewPolicyAuthorizer(NewLogger("error"), path, nil)
+	if err != nil {
+		t.Fatalf("Failed to create authorizer: %v", err)
+	}
+	withoutPolicy, err := NewPolicyAuthorizer(NewLogger("error"), "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create authorizer: %v", err)
+	}
+
+	for _, role := range []string{RoleApprover, RoleTrusted} {
+		if allowed, err := withPolicy.Authorize(context.Background(), "U1", "my-org", role); err != nil || allowed {
+			t.Errorf("Expected %s to be closed when the policy does not define it, got %v, %v", role, allowed, err)
+		}
+		if allowed, err := withoutPolicy.Authorize(context.Background(), "U1", "my-org", role); err != nil || !allowed {
+			t.Errorf("Expected %s to be open without a policy file, got %v, %v", role, allowed, err)
+		}
+	}
+}
+
+// TestLoadPolicyYAML tests that a YAML policy loads the same as its JSON equivalent
+func TestLoadPolicyYAML(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath, jsonPath := filepath.Join(dir, "policy.yaml"), filepath.Join(dir, "policy.json")
+	writePolicy(t, yamlPath, `
+roles:
+  creator:
+    users: [U1]
+    usergroups: [S1]
+orgs:
+  secret-org:
+    roles:
+      creator:
+        users: [U4]
+`, time.Now())
+	writePolicy(t, jsonPath, `{"roles":{"creator":{"users":["U1"],"usergroups":["S1"]}},"orgs":{"secret-org":{"roles":{"creator":{"users":["U4"]}}}}}`, time.Now())
+
+	fromYAML, err := loadPolicy(yamlPath)
+	if err != nil {
+		t.Fatalf("loadPolicy() failed for YAML: %v", err)
+	}
+	fromJSON, err := loadPolicy(jsonPath)
+	if err != nil {
+		t.Fatalf("loadPolicy() failed for JSON: %v", err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("Expected the same policy from both files, got %+v and %+v", fromYAML, fromJSON)
+	}
+	if members, _ := fromYAML.roleMembers("secret-org", RoleCreator); !reflect.DeepEqual(members.Users, []string{"U4"}) {
+		t.Errorf("Unexpected secret-org creators: %+v", members)
+	}
+}
+
+// TestPolicyAuthorizerReload tests that a changed policy file is picked up and a broken one ignored
+func TestPolicyAuthorizerReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	modTime := time.Now().Add(-time.Hour)
+	writePolicy(t, path, `{"roles":{"creator":{"users":["U1"]}}}`, modTime)
+
+	authorizer, err := NewPolicyAuthorizer(NewLogger("error"), path, nil)
+	if err != nil {
+		t.Fatalf("Failed to create authorizer: %v", err)
+	}
+	ctx := context.Background()
+
+	if allowed, _ := authorizer.Authorize(ctx, "U2", "my-org", RoleCreator); allowed {
+		t.Error("Expected U2 to be denied before the reload")
+	}
+
+	modTime = modTime.Add(time.Minute)
+	writePolicy(t, path, `{"roles":{"creator":{"users":["U2"]}}}`, modTime)
+	if allowed, _ := authorizer.Authorize(ctx, "U2", "my-org", RoleCreator); !allowed {
+		t.Error("Expected U2 to be allowed after the reload")
+	}
+
+	modTime = modTime.Add(time.Minute)
+	writePolicy(t, path, `{"roles":`, modTime)
+	if allowed, _ := authorizer.Authorize(ctx, "U2", "my-org", RoleCreator); !allowed {
+		t.Error("Expected the previous policy to stay in force when the new one is invalid")
+	}
+}
+
+// TestNewPolicyAuthorizerErrors tests that a missing or invalid policy fails at startup
+func TestNewPolicyAuthorizerErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewPolicyAuthorizer(NewLogger("error"), filepath.Join(dir, "missing.json"), nil); err == nil {
+		t.Error("Expected an error for a missing policy file")
+	}
+
+	path := filepath.Join(dir, "invalid.json")
+	for name, policy := range map[string]string{
+		"Invalid":      `not json`,
+		"Empty":        ``,
+		"UnknownField": "roles:\n  creator:\n    user: [U1]\n",
+	} {
+		writePolicy(t, path, policy, time.Now())
+		if _, err := NewPolicyAuthorizer(NewLogger("error"), path, nil); err == nil {
+			t.Errorf("%s: expected an error for an invalid policy file", name)
+		}
+	}
+
+	authorizer, err := NewPolicyAuthorizer(NewLogger("error"), "", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error without a policy file: %v", err)
+	}
+	if allowed, _ := authorizer.Authorize(context.Background(), "anyone", "my-org", RoleCreator); !allowed {
+		t.Error("Expected everyone to be allowed without a policy file")
+	}
+}
+
+// TestHandleViewSubmissionNotAuthorized tests that denied users get an error in the modal
+func TestHandleViewSubmissionNotAuthorized(t *testing.T) {
+	var submission ViewSubmissionPayload
+	payload := `{"type":"view_submission","user":{"id":"U9"},"view":{"id":"V1","callback_id":"create_github_repo_modal","state":{"values":{
+		"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"my-repo"}}}}}}`
+	if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+
+	config := &Config{GithubOrg: "my-org"}
+	for name, authorizer := range map[string]Authorizer{
+		"Denied": &fakeAuthorizer{allowed: map[string]bool{"U1": true}},
+		"Error":  &fakeAuthorizer{err: errors.New("slack unavailable")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// The check happens before anything is written to Redis, so no client is needed
+			service := &Service{Logger: NewLogger("error"), Configs: NewConfigStore(config), RepoChecker: &fakeRepoChecker{}, Authorizer: authorizer}
+			response, err := service.handleViewSubmission(context.Background(), &submission)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response == nil || !strings.Contains(response.Errors["repo-name"], "not allowed") {
+				t.Errorf("Expected a not allowed error, got %+v", response)
+			}
+		})
+	}
+}
+
+// TestHandleViewSubmissionPerOrg tests that the selected organization must be configured and permitted
+func TestHandleViewSubmissionPerOrg(t *testing.T) {
+	config := &Config{GithubOrg: "org-a", GithubOrgs: []string{"org-a", "org-b"}}
+	authorizer := &fakeAuthorizer{orgs: map[string]bool{"org-a": true}}
+
+	submit := func(org string) *slack.ViewSubmissionResponse {
+		t.Helper()
+		var submission ViewSubmissionPayload
+		payload := `{"type":"view_submission","user":{"id":"U1"},"view":{"id":"V1","callback_id":"create_github_repo_modal","state":{"values":{
+			"repo-org":{"repo_org_select":{"type":"static_select","selected_option":{"value":"` + org + `"}}},
+			"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"taken"}}}}}}`
+		if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+			t.Fatalf("Failed to unmarshal payload: %v", err)
+		}
+		service, _ := newTestService(t, config)
+		service.RepoChecker = &fakeRepoChecker{existing: map[string]bool{"org-a/taken": true}}
+		service.Authorizer = authorizer
+		response, err := service.handleViewSubmission(context.Background(), &submission)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response == nil {
+			t.Fatal("Expected an errors response")
+		}
+		return response
+	}
+
+	// The repository is looked up in the selected organization
+	if msg := submit("org-a").Errors["repo-name"]; !strings.Contains(msg, "org-a/taken already exists") {
+		t.Errorf("Expected the name to be checked in org-a, got %q", msg)
+	}
+	if msg := submit("org-b").Errors["repo-name"]; !strings.Contains(msg, "not allowed to create repositories in org-b") {
+		t.Errorf("Expected a denial for org-b, got %q", msg)
+	}
+	if msg := submit("org-c").Errors["repo-org"]; msg == "" {
+		t.Error("Expected an error for an organization that is not configured")
+	}
+}
+
+// TestCreatorOrgs tests that only permitted organizations are offered
+func TestCreatorOrgs(t *testing.T) {
+	config := &Config{GithubOrg: "org-a", GithubOrgs: []string{"org-a", "org-b", "org-c"}}
+	authorizer := &fakeAuthorizer{orgs: map[string]bool{"org-b": true, "org-c": true}}
+
+	orgs := creatorOrgs(context.Background(), NewLogger("error"), authorizer, config, "U1", AuthStageCommand)
+	if !reflect.DeepEqual(orgs, []string{"org-b", "org-c"}) {
+		t.Errorf("creatorOrgs() = %v", orgs)
+	}
+
+	failing := &fakeAuthorizer{err: errors.New("slack unavailable")}
+	if orgs := creatorOrgs(context.Background(), NewLogger("error"), failing, config, "U1", AuthStageCommand); len(orgs) != 0 {
+		t.Errorf("Expected no organizations when authorization fails, got %v", orgs)
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// HelpKeyword is the command text that asks a command for its help text
+// It is matched ignoring case and surrounding spaces, so it always shows the help
+const HelpKeyword = "help"
+
+// ViewFailureMessage is shown in the modal when a submission could not be processed
+const ViewFailureMessage = "Something went wrong, please try again"
+
+// SlashCommand describes a slash command, the modals it owns and how to handle them
+type SlashCommand struct {
+	// Name is the slash command including the leading slash, e.g. "/new-repo"
+	Name string
+	// Help is a short description shown when the command is run with "help"
+	Help string
+	// CallbackIDs lists the modal callback IDs whose submissions belong to this command
+	CallbackIDs []string
+	// HandleCommand is called when the slash command is received
+	HandleCommand func(ctx context.Context, cmd *SlashCommandPayload)
+	// HandleViewSubmission is called when one of the command's modals is submitted
+	// A nil response closes the modal, a non-nil response (e.g. errors) is returned to Slack
+	// Errors that are not a *HandlerError are treated as retryable
+	HandleViewSubmission func(ctx context.Context, submission *ViewSubmissionPayload) (*slack.ViewSubmissionResponse, error)
+	// ErrorBlockID is the modal block that failures of HandleViewSubmission are reported against
+	ErrorBlockID string
+	// ActionIDs lists the block action IDs (e.g. message buttons) that belong to this command
+	ActionIDs []string
+	// HandleBlockAction is called for each of the command's actions in a block_actions payload
+	// Errors that are not a *HandlerError are treated as retryable
+	HandleBlockAction func(ctx context.Context, payload *BlockActionsPayload, action *BlockAction) error
+}
+
+// ViewResponder sends the response to a view submission back to the relay
+type ViewResponder interface {
+	RespondToView(ctx context.Context, viewID string, response *slack.ViewSubmissionResponse) error
+}
+
+// RedisViewResponder pushes view submission responses to a Redis list keyed by view ID
+// The relay waits on "<prefix>:<view_id>" (e.g. with BLPOP) and returns the JSON body to Slack
+type RedisViewResponder struct {
+	client *redis.Client
+	prefix string
+}
+
+// RespondToView pushes the response for viewID; a nil response is sent as an empty object
+func (r *RedisViewResponder) RespondToView(ctx context.Context, viewID string, response *slack.ViewSubmissionResponse) error {
+	body := []byte("{}")
+	if response != nil {
+		var err error
+		body, err = json.Marshal(response)
+		if err != nil {
+			return fmt.Errorf("failed to marshal view response: %w", err)
+		}
+	}
+
+	key := fmt.Sprintf("%s:%s", r.prefix, viewID)
+	pipe := r.client.TxPipeline()
+	pipe.RPush(ctx, key, string(body))
+	pipe.Expire(ctx, key, ViewResponseTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to push view response to %s: %w", key, err)
+	}
+	return nil
+}
+
+// CommandRouter dispatches slash commands and view submissions to registered commands
+type CommandRouter struct {
+	logger    *Logger
+	responder ViewResponder
+	verifier  atomic.Pointer[PayloadVerifier]
+	commands  map[string]*SlashCommand
+	callbacks map[string]*SlashCommand
+	actions   map[string]*SlashCommand
+}
+
+// NewCommandRouter creates an empty CommandRouter that replies to view submissions via responder
+// Payloads rejected by verifier are dropped; a nil verifier accepts every payload
+func NewCommandRouter(logger *Logger, responder ViewResponder, verifier *PayloadVerifier) *CommandRouter {
+	router := &CommandRouter{
+		logger:    logger,
+		responder: responder,
+		commands:  make(map[string]*SlashCommand),
+		callbacks: make(map[string]*SlashCommand),
+		actions:   make(map[string]*SlashCommand),
+	}
+	router.verifier.Store(verifier)
+	return router
+}
+
+// SetVerifier replaces the verifier, e.g. after the allow-lists are reloaded
+func (r *CommandRouter) SetVerifier(verifier *PayloadVerifier) {
+	r.verifier.Store(verifier)
+}
+
+// Register adds a command to the router
+// It fails if the command name or any of its callback IDs is already registered
+func (r *CommandRouter) Register(command *SlashCommand) error {
+	if command.Name == "" || command.HandleCommand == nil {
+		return fmt.Errorf("command must have a name and a command handler")
+	}
+	if _, exists := r.commands[command.Name]; exists {
+		return fmt.Errorf("command %s is already registered", command.Name)
+	}
+	if len(command.CallbackIDs) > 0 && (command.HandleViewSubmission == nil || command.ErrorBlockID == "") {
+		return fmt.Errorf("command %s has callback IDs but no view submission handler or error block ID", command.Name)
+	}
+	for _, callbackID := range command.CallbackIDs {
+		if owner, exists := r.callbacks[callbackID]; exists {
+			return fmt.Errorf("callback_id %s is already registered by %s", callbackID, owner.Name)
+		}
+	}
+	if len(command.ActionIDs) > 0 && command.HandleBlockAction == nil {
+		return fmt.Errorf("command %s has action IDs but no block action handler", command.Name)
+	}
+	for _, actionID := range command.ActionIDs {
+		if owner, exists := r.actions[actionID]; exists {
+			return fmt.Errorf("action_id %s is already registered by %s", actionID, owner.Name)
+		}
+	}
+
+	r.commands[command.Name] = command
+	for _, callbackID := range command.CallbackIDs {
+		r.callbacks[callbackID] = command
+	}
+	for _, actionID := range command.ActionIDs {
+		r.actions[actionID] = command
+	}
+
+	r.logger.Info("Registered command", "command", command.Name, "callback_ids", strings.Join(command.CallbackIDs, ","), "action_ids", strings.Join(command.ActionIDs, ","))
+	return nil
+}
+
+// Commands returns the registered commands sorted by name
+func (r *CommandRouter) Commands() []*SlashCommand {
+	commands := make([]*SlashCommand, 0, len(r.commands))
+	for _, command := range r.commands {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// HandleMessage processes a slash command payload from Redis
+// It returns a *HandlerError if the payload could not be processed
+func (r *CommandRouter) HandleMessage(ctx context.Context, payload string) error {
+	defer observeHandlerDuration("slash_command", time.Now())
+	r.logger.Debug("Received message", "payload", payload)
+
+	var cmd SlashCommandPayload
+	if err := json.Unmarshal([]byte(payload), &cmd); err != nil {
+		return &HandlerError{Stage: StageParseCommand, Err: fmt.Errorf("failed to unmarshal payload: %w", err)}
+	}
+
+	command, ok := r.commands[cmd.Command]
+	if !ok {
+		slashCommandsTotal.WithLabelValues(otherLabel).Inc()
+		r.logger.Warn("Unknown command", "command", cmd.Command)
+		return nil
+	}
+	slashCommandsTotal.WithLabelValues(command.Name).Inc()
+
+	// Forged payloads are dropped without a reply, since their response_url cannot be trusted either
+	if reason := r.verifier.Load().Verify(cmd.Token, cmd.TeamID, cmd.APIAppID); reason != "" {
+		rejectedPayloadsTotal.WithLabelValues("slash_command", reason).Inc()
+		r.logger.Warn("Rejected slash command", "command", cmd.Command, "user_id", cmd.UserID, "team_id", cmd.TeamID, "api_app_id", cmd.APIAppID, "reason", reason)
+		return nil
+	}
+
+	r.logger.Info("Processing command", "command", cmd.Command, "user_id", cmd.UserID, "user_name", cmd.UserName)
+
+	if strings.EqualFold(strings.TrimSpace(cmd.Text), HelpKeyword) {
+		respondEphemeral(ctx, r.logger, cmd.ResponseURL, fmt.Sprintf("*%s* - %s", command.Name, command.Help))
+		return nil
+	}
+
+	command.HandleCommand(ctx, &cmd)
+	return nil
+}
+
+// HandleViewSubmission processes a view submission payload from Redis
+// It returns a *HandlerError if the payload could not be processed
+func (r *CommandRouter) HandleViewSubmission(ctx context.Context, payload string) error {
+	defer observeHandlerDuration("view_submission", time.Now())
+	r.logger.Debug("Received view submission", "payload", payload)
+
+	var submission ViewSubmissionPayload
+	if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+		return &HandlerError{Stage: StageParseViewSubmission, Err: fmt.Errorf("failed to unmarshal view submission payload: %w", err)}
+	}
+
+	// Only handle callback IDs owned by a registered command
+	command, ok := r.callbacks[submission.View.CallbackID]
+	if !ok {
+		viewSubmissionsTotal.WithLabelValues(otherLabel).Inc()
+		r.logger.Debug("Ignoring view submission", "callback_id", submission.View.CallbackID)
+		return nil
+	}
+	viewSubmissionsTotal.WithLabelValues(submission.View.CallbackID).Inc()
+
+	if reason := r.verifier.Load().Verify(submission.Token, submission.Team.ID, submission.APIAppID); reason != "" {
+		rejectedPayloadsTotal.WithLabelValues("view_submission", reason).Inc()
+		r.logger.Warn("Rejected view submission", "callback_id", submission.View.CallbackID, "view_id", submission.View.ID, "team_id", submission.Team.ID, "api_app_id", submission.APIAppID, "reason", reason)
+		return nil
+	}
+
+	response, err := command.HandleViewSubmission(ctx, &submission)
+	if err != nil {
+		var handlerErr *HandlerError
+		if !errors.As(err, &handlerErr) {
+			handlerErr = &HandlerError{Stage: StageHandleView, Err: fmt.Errorf("%s: %w", command.Name, err), Retryable: true}
+		}
+		// Tell the user now; a retry happens after the relay has stopped waiting
+		r.respondToView(ctx, &submission, slack.NewErrorsViewSubmissionResponse(map[string]string{command.ErrorBlockID: ViewFailureMessage}))
+		return handlerErr
+	}
+
+	r.respondToView(ctx, &submission, response)
+	return nil
+}
+
+// respondToView sends the response for a view submission
+// Always reply so the relay does not have to wait for its timeout
+func (r *CommandRouter) respondToView(ctx context.Context, submission *ViewSubmissionPayload, response *slack.ViewSubmissionResponse) {
+	if submission.View.ID == "" {
+		r.logger.Debug("View submission has no view ID, not sending a response", "callback_id", submission.View.CallbackID)
+		return
+	}
+	if err := r.responder.RespondToView(ctx, submission.View.ID, response); err != nil {
+		pushErrorsTotal.WithLabelValues(PushTargetViewResponse).Inc()
+		r.logger.Error("Failed to respond to view", "view_id", submission.View.ID, "error", err)
+		return
+	}
+	r.logger.Debug("Sent response for view", "view_id", submission.View.ID)
+}
+
+// HandleBlockActions processes a block_actions payload (e.g. a button click) from Redis
+// It returns a *HandlerError if the payload could not be processed
+func (r *CommandRouter) HandleBlockActions(ctx context.Context, payload string) error {
+	defer observeHandlerDuration("block_actions", time.Now())
+	r.logger.Debug("Received block actions", "payload", payload)
+
+	var actions BlockActionsPayload
+	if err := json.Unmarshal([]byte(payload), &actions); err != nil {
+		return &HandlerError{Stage: StageParseBlockActions, Err: fmt.Errorf("failed to unmarshal block actions payload: %w", err)}
+	}
+
+	verified := false
+	for i := range actions.Actions {
+		action := &actions.Actions[i]
+
+		// Only handle action IDs owned by a registered command
+		command, ok := r.actions[action.ActionID]
+		if !ok {
+			blockActionsTotal.WithLabelValues(otherLabel).Inc()
+			r.logger.Debug("Ignoring block action", "action_id", action.ActionID)
+			continue
+		}
+		blockActionsTotal.WithLabelValues(action.ActionID).Inc()
+
+		if !verified {
+			if reason := r.verifier.Load().Verify(actions.Token, actions.Team.ID, actions.APIAppID); reason != "" {
+				rejectedPayloadsTotal.WithLabelValues("block_actions", reason).Inc()
+				r.logger.Warn("Rejected block actions", "action_id", action.ActionID, "user_id", actions.User.ID, "team_id", actions.Team.ID, "api_app_id", actions.APIAppID, "reason", reason)
+				return nil
+			}
+			verified = true
+		}
+
+		if err := command.HandleBlockAction(ctx, &actions, action); err != nil {
+			var handlerErr *HandlerError
+			if errors.As(err, &handlerErr) {
+				return handlerErr
+			}
+			return &HandlerError{Stage: StageHandleBlockAction, Err: fmt.Errorf("%s: %w", command.Name, err), Retryable: true}
+		}
+	}
+	return nil
+}
+
+// registerCommands registers every command supported by the service
+// Add new commands here; main does not need to change
+func registerCommands(router *CommandRouter, service *Service) error {
+	commands := []*SlashCommand{
+		newRepoCommand(service),
+	}
+
+	for _, command := range commands {
+		if err := router.Register(command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newRepoCommand builds the /new-repo command
+// Each handler reads the configuration when it is called, so reloads apply to the next payload
+func newRepoCommand(service *Service) *SlashCommand {
+	return &SlashCommand{
+		Name:                 "/new-repo",
+		Help:                 "Open a modal to create a new GitHub repository. Usage: `/new-repo [repo-name]`. To name a repository `help`, run `/new-repo` and type the name in the modal.",
+		CallbackIDs:          []string{NewRepoModalCallbackID},
+		HandleCommand:        service.handleNewRepoCommand,
+		HandleViewSubmission: service.handleViewSubmission,
+		ErrorBlockID:         "repo-name",
+		ActionIDs:            []string{ActionApproveRepo, ActionRejectRepo},
+		HandleBlockAction:    service.handleApprovalAction,
+	}
+}
+
+// respondEphemeral sends a message only visible to the user via the slash command response URL
+func respondEphemeral(ctx context.Context, logger *Logger, responseURL, text string) {
+	if responseURL == "" {
+		logger.Warn("Cannot send ephemeral response without a response_url")
+		return
+	}
+
+	err := slack.PostWebhookContext(ctx, responseURL, &slack.WebhookMessage{
+		Text:         text,
+		ResponseType: slack.ResponseTypeEphemeral,
+	})
+	if err != nil {
+		slackAPIErrorsTotal.WithLabelValues(SlackMethodResponseURL).Inc()
+		logger.Error("Failed to send ephemeral response", "error", err)
+	}
+}
+
+// replaceOriginal replaces the message an interaction came from, e.g. to remove its buttons
+func replaceOriginal(ctx context.Context, logger *Logger, responseURL, text string) {
+	if responseURL == "" {
+		logger.Warn("Cannot replace message without a response_url")
+		return
+	}
+	err := slack.PostWebhookContext(ctx, responseURL, &slack.WebhookMessage{Text: text, ReplaceOriginal: true})
+	if err != nil {
+		slackAPIErrorsTotal.WithLabelValues(SlackMethodResponseURL).Inc()
+		logger.Error("Failed to replace original message", "error", err)
+	}
+}
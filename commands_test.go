@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+// fakeViewResponder records view submission responses instead of sending them
+type fakeViewResponder struct {
+	responses map[string]*slack.ViewSubmissionResponse
+}
+
+func (f *fakeViewResponder) RespondToView(ctx context.Context, viewID string, response *slack.ViewSubmissionResponse) error {
+	if f.responses == nil {
+		f.responses = make(map[string]*slack.ViewSubmissionResponse)
+	}
+	f.responses[viewID] = response
+	return nil
+}
+
+// TestCommandRouterRegister tests that duplicate commands and callback IDs are rejected
+func TestCommandRouterRegister(t *testing.T) {
+	noop := func(ctx context.Context, cmd *SlashCommandPayload) {}
+	noopView := func(ctx context.Context, submission *ViewSubmissionPayload) (*slack.ViewSubmissionResponse, error) {
+		return nil, nil
+	}
+
+	tests := []struct {
+		name    string
+		command *SlashCommand
+		wantErr bool
+	}{
+		{"Valid", &SlashCommand{Name: "/other", CallbackIDs: []string{"other_modal"}, HandleCommand: noop, HandleViewSubmission: noopView, ErrorBlockID: "repo-name"}, false},
+		{"MissingName", &SlashCommand{HandleCommand: noop}, true},
+		{"MissingHandler", &SlashCommand{Name: "/other"}, true},
+		{"MissingViewHandler", &SlashCommand{Name: "/other", CallbackIDs: []string{"other_modal"}, HandleCommand: noop}, true},
+		{"DuplicateName", &SlashCommand{Name: "/first", HandleCommand: noop}, true},
+		{"DuplicateCallbackID", &SlashCommand{Name: "/other", CallbackIDs: []string{"first_modal"}, HandleCommand: noop, HandleViewSubmission: noopView, ErrorBlockID: "repo-name"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewCommandRouter(NewLogger("error"), &fakeViewResponder{}, nil)
+			first := &SlashCommand{Name: "/first", CallbackIDs: []string{"first_modal"}, HandleCommand: noop, HandleViewSubmission: noopView, ErrorBlockID: "repo-name"}
+			if err := router.Register(first); err != nil {
+				t.Fatalf("Failed to register first command: %v", err)
+			}
+
+			err := router.Register(tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCommandRouterDispatch tests that commands and view submissions reach their owning handler
+func TestCommandRouterDispatch(t *testing.T) {
+	router := NewCommandRouter(NewLogger("error"), &fakeViewResponder{}, nil)
+
+	var gotCommands []string
+	var gotCallbacks []string
+	for _, name := range []string{"/alpha", "/beta"} {
+		name := name
+		callbackID := strings.TrimPrefix(name, "/") + "_modal"
+		err := router.Register(&SlashCommand{
+			Name:         name,
+			CallbackIDs:  []string{callbackID},
+			ErrorBlockID: "repo-name",
+			HandleCommand: func(ctx context.Context, cmd *SlashCommandPayload) {
+				gotCommands = append(gotCommands, name+":"+cmd.Text)
+			},
+			HandleViewSubmission: func(ctx context.Context, submission *ViewSubmissionPayload) (*slack.ViewSubmissionResponse, error) {
+				gotCallbacks = append(gotCallbacks, name+":"+submission.View.CallbackID)
+				return nil, nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to register %s: %v", name, err)
+		}
+	}
+
+	ctx := context.Background()
+	router.HandleMessage(ctx, `{"command":"/beta","text":"hello"}`)
+	router.HandleMessage(ctx, `{"command":"/unknown","text":"ignored"}`)
+	router.HandleMessage(ctx, `not json`)
+	router.HandleViewSubmission(ctx, `{"type":"view_submission","view":{"callback_id":"alpha_modal"}}`)
+	router.HandleViewSubmission(ctx, `{"type":"view_submission","view":{"callback_id":"unknown_modal"}}`)
+
+	if len(gotCommands) != 1 || gotCommands[0] != "/beta:hello" {
+		t.Errorf("Unexpected commands dispatched: %v", gotCommands)
+	}
+	if len(gotCallbacks) != 1 || gotCallbacks[0] != "/alpha:alpha_modal" {
+		t.Errorf("Unexpected view submissions dispatched: %v", gotCallbacks)
+	}
+
+	names := []string{}
+	for _, command := range router.Commands() {
+		names = append(names, command.Name)
+	}
+	if strings.Join(names, ",") != "/alpha,/beta" {
+		t.Errorf("Commands() = %v, want sorted [/alpha /beta]", names)
+	}
+}
+
+// TestCommandRouterHelp tests that "help" responds with the command help instead of running it
+func TestCommandRouterHelp(t *testing.T) {
+	recorder := newResponseRecorder(t)
+	router := NewCommandRouter(NewLogger("error"), &fakeViewResponder{}, nil)
+	called := false
+	err := router.Register(&SlashCommand{
+		Name:          "/alpha",
+		Help:          "Does alpha things",
+		HandleCommand: func(ctx context.Context, cmd *SlashCommandPayload) { called = true },
+	})
+	if err != nil {
+		t.Fatalf("Failed to register command: %v", err)
+	}
+
+	// The keyword is matched ignoring case and surrounding spaces
+	for _, text := range []string{"help", " Help ", "HELP"} {
+		payload, _ := json.Marshal(SlashCommandPayload{Command: "/alpha", Text: text, ResponseURL: recorder.URL})
+		router.HandleMessage(context.Background(), string(payload))
+	}
+
+	if called {
+		t.Error("Expected command handler not to be called for help")
+	}
+	got := recorder.Messages()
+	if len(got) != 3 {
+		t.Fatalf("Expected a help response for each spelling, got %+v", got)
+	}
+	for _, message := range got {
+		if message.ResponseType != slack.ResponseTypeEphemeral || !strings.Contains(message.Text, "Does alpha things") {
+			t.Errorf("Unexpected help response: %+v", message)
+		}
+	}
+
+	// Anything else runs the command
+	payload, _ := json.Marshal(SlashCommandPayload{Command: "/alpha", Text: "helpful", ResponseURL: recorder.URL})
+	router.HandleMessage(context.Background(), string(payload))
+	if !called {
+		t.Error("Expected the command handler to be called for other text")
+	}
+}
+
+// TestCommandRouterViewResponse tests that the handler's response is sent back keyed by view ID
+func TestCommandRouterViewResponse(t *testing.T) {
+	responder := &fakeViewResponder{}
+	router := NewCommandRouter(NewLogger("error"), responder, nil)
+	err := router.Register(&SlashCommand{
+		Name:          "/alpha",
+		CallbackIDs:   []string{"alpha_modal"},
+		ErrorBlockID:  "repo-name",
+		HandleCommand: func(ctx context.Context, cmd *SlashCommandPayload) {},
+		HandleViewSubmission: func(ctx context.Context, submission *ViewSubmissionPayload) (*slack.ViewSubmissionResponse, error) {
+			switch submission.View.ID {
+			case "V_ERR":
+				return slack.NewErrorsViewSubmissionResponse(map[string]string{"repo-name": "bad"}), nil
+			case "V_RETRY":
+				return nil, errors.New("queue unavailable")
+			}
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register command: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, payload := range []string{
+		`{"view":{"id":"V_OK","callback_id":"alpha_modal"}}`,
+		`{"view":{"id":"V_ERR","callback_id":"alpha_modal"}}`,
+		`{"view":{"callback_id":"alpha_modal"}}`,
+	} {
+		if err := router.HandleViewSubmission(ctx, payload); err != nil {
+			t.Errorf("HandleViewSubmission(%s) returned unexpected error: %v", payload, err)
+		}
+	}
+
+	// Handler errors are returned for a retry, and the user is told straight away
+	var handlerErr *HandlerError
+	if err := router.HandleViewSubmission(ctx, `{"view":{"id":"V_RETRY","callback_id":"alpha_modal"}}`); !errors.As(err, &handlerErr) || !handlerErr.Retryable {
+		t.Errorf("Expected a retryable error for V_RETRY, got %v", err)
+	}
+
+	if len(responder.responses) != 3 {
+		t.Fatalf("Expected 3 responses, got %d: %v", len(responder.responses), responder.responses)
+	}
+	if resp, ok := responder.responses["V_OK"]; !ok || resp != nil {
+		t.Errorf("Expected nil response for V_OK, got %+v (present: %v)", resp, ok)
+	}
+	resp := responder.responses["V_ERR"]
+	if resp == nil || resp.ResponseAction != slack.RAErrors || resp.Errors["repo-name"] != "bad" {
+		t.Errorf("Unexpected response for V_ERR: %+v", resp)
+	}
+	resp = responder.responses["V_RETRY"]
+	if resp == nil || resp.ResponseAction != slack.RAErrors || resp.Errors["repo-name"] != ViewFailureMessage {
+		t.Errorf("Expected the failure to be reported against repo-name for V_RETRY, got %+v", resp)
+	}
+}
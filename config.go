@@ -0,0 +1,425 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secret values in --print-config output
+const redacted = "<redacted>"
+
+// githubLoginPattern matches a GitHub user login: alphanumerics separated by single hyphens
+var githubLoginPattern = regexp.MustCompile(`^[A-Za-z0-9]+(?:-[A-Za-z0-9]+)*$`)
+
+// MaxGithubLoginLength is the longest login GitHub allows
+const MaxGithubLoginLength = 39
+
+// Config holds the application configuration
+type Config struct {
+	RedisAddr                  string
+	RedisPassword              string
+	RedisChannel               string
+	RedisViewSubmissionChannel string
+	RedisViewResponsePrefix    string
+	RedisPoppitList            string
+	RedisPoppitOutputChannel   string
+	RedisSlackLinerList        string
+	SlackToken                 string
+	SlackChannelNewRepo        string
+	GithubOrg                  string
+	GithubOrgs                 []string
+	ChannelOrgs                map[string]string
+	GithubUsers                map[string]string
+	GithubToken                string
+	GithubAPIURL               string
+	WorkingDir                 string
+	LogLevel                   string
+	LogFormat                  string
+	HTTPAddr                   string
+	AuthPolicyFile             string
+	TemplatesFile              string
+	ApproversChannel           string
+	RedisBlockActionsChannel   string
+	ApprovalTTL                time.Duration
+	RateLimitUser              *RateLimit
+	RateLimitOrg               *RateLimit
+	SlackVerificationToken     string
+	AllowedTeamIDs             []string
+	AllowedAppIDs              []string
+	Transport                  string
+	RedisStreamGroup           string
+	RedisStreamConsumer        string
+	StreamClaimMinIdle         time.Duration
+	StreamMaxDeliveries        int
+	RedisDeadLetterList        string
+}
+
+// setting is a single configuration value, read from the config file and the environment
+// Its key in the config file is the environment variable name in lower case
+type setting struct {
+	Env     string
+	Default string
+	Secret  bool
+	// Restart is set for settings that are only read at startup, so a reload cannot change them
+	Restart bool
+	// Field returns a pointer to the Config field the value is parsed into: *string,
+	// *[]string (comma-separated or a YAML list), *map[string]string (comma-separated
+	// key=value pairs or a YAML mapping), *time.Duration, *int or **RateLimit
+	Field func(c *Config) interface{}
+}
+
+// Key returns the setting's key in the config file
+func (s *setting) Key() string {
+	return strings.ToLower(s.Env)
+}
+
+// settings lists every configuration value in the order --print-config shows them
+var settings = []setting{
+	{Env: "REDIS_ADDR", Default: "localhost:6379", Restart: true, Field: func(c *Config) interface{} { return &c.RedisAddr }},
+	{Env: "REDIS_PASSWORD", Secret: true, Restart: true, Field: func(c *Config) interface{} { return &c.RedisPassword }},
+	{Env: "REDIS_CHANNEL", Default: "slack-commands", Field: func(c *Config) interface{} { return &c.RedisChannel }},
+	{Env: "REDIS_VIEW_SUBMISSION_CHANNEL", Default: "slack-relay-view-submission", Field: func(c *Config) interface{} { return &c.RedisViewSubmissionChannel }},
+	{Env: "REDIS_VIEW_RESPONSE_PREFIX", Default: "slack-relay-view-response", Restart: true, Field: func(c *Config) interface{} { return &c.RedisViewResponsePrefix }},
+	{Env: "REDIS_BLOCK_ACTIONS_CHANNEL", Default: "slack-relay-block-actions", Field: func(c *Config) interface{} { return &c.RedisBlockActionsChannel }},
+	{Env: "REDIS_POPPIT_LIST", Default: "poppit:notifications", Field: func(c *Config) interface{} { return &c.RedisPoppitList }},
+	{Env: "REDIS_POPPIT_OUTPUT_CHANNEL", Default: "poppit:command-output", Field: func(c *Config) interface{} { return &c.RedisPoppitOutputChannel }},
+	{Env: "REDIS_SLACKLINER_LIST", Default: "slack_messages", Field: func(c *Config) interface{} { return &c.RedisSlackLinerList }},
+	{Env: "REDIS_DEAD_LETTER_LIST", Default: "slashviberepo:dead-letter", Field: func(c *Config) interface{} { return &c.RedisDeadLetterList }},
+	{Env: "TRANSPORT", Default: TransportPubSub, Restart: true, Field: func(c *Config) interface{} { return &c.Transport }},
+	{Env: "REDIS_STREAM_GROUP", Default: "slashviberepo", Restart: true, Field: func(c *Config) interface{} { return &c.RedisStreamGroup }},
+	{Env: "REDIS_STREAM_CONSUMER", Default: defaultConsumerName(), Restart: true, Field: func(c *Config) interface{} { return &c.RedisStreamConsumer }},
+	{Env: "STREAM_CLAIM_MIN_IDLE", Default: "1m", Restart: true, Field: func(c *Config) interface{} { return &c.StreamClaimMinIdle }},
+	{Env: "STREAM_MAX_DELIVERIES", Default: "5", Field: func(c *Config) interface{} { return &c.StreamMaxDeliveries }},
+	{Env: "SLACK_BOT_TOKEN", Secret: true, Restart: true, Field: func(c *Config) interface{} { return &c.SlackToken }},
+	{Env: "SLACK_VERIFICATION_TOKEN", Secret: true, Field: func(c *Config) interface{} { return &c.SlackVerificationToken }},
+	{Env: "ALLOWED_TEAM_IDS", Field: func(c *Config) interface{} { return &c.AllowedTeamIDs }},
+	{Env: "ALLOWED_APP_IDS", Field: func(c *Config) interface{} { return &c.AllowedAppIDs }},
+	{Env: "SLACK_CHANNEL_NEW_REPO", Default: "#new-repo", Field: func(c *Config) interface{} { return &c.SlackChannelNewRepo }},
+	{Env: "APPROVERS_CHANNEL", Field: func(c *Config) interface{} { return &c.ApproversChannel }},
+	{Env: "APPROVAL_TTL", Default: "24h", Field: func(c *Config) interface{} { return &c.ApprovalTTL }},
+	{Env: "AUTH_POLICY_FILE", Restart: true, Field: func(c *Config) interface{} { return &c.AuthPolicyFile }},
+	{Env: "TEMPLATES_FILE", Restart: true, Field: func(c *Config) interface{} { return &c.TemplatesFile }},
+	{Env: "RATE_LIMIT_USER", Field: func(c *Config) interface{} { return &c.RateLimitUser }},
+	{Env: "RATE_LIMIT_ORG", Field: func(c *Config) interface{} { return &c.RateLimitOrg }},
+	{Env: "GITHUB_ORG", Field: func(c *Config) interface{} { return &c.GithubOrg }},
+	{Env: "GITHUB_ORGS", Field: func(c *Config) interface{} { return &c.GithubOrgs }},
+	{Env: "CHANNEL_ORGS", Field: func(c *Config) interface{} { return &c.ChannelOrgs }},
+	{Env: "GITHUB_USERS", Field: func(c *Config) interface{} { return &c.GithubUsers }},
+	{Env: "GITHUB_TOKEN", Secret: true, Restart: true, Field: func(c *Config) interface{} { return &c.GithubToken }},
+	{Env: "GITHUB_API_URL", Default: DefaultGitHubAPIURL, Restart: true, Field: func(c *Config) interface{} { return &c.GithubAPIURL }},
+	{Env: "WORKING_DIR", Default: "/tmp", Field: func(c *Config) interface{} { return &c.WorkingDir }},
+	{Env: "LOG_LEVEL", Default: "info", Restart: true, Field: func(c *Config) interface{} { return &c.LogLevel }},
+	{Env: "LOG_FORMAT", Default: LogFormatText, Restart: true, Field: func(c *Config) interface{} { return &c.LogFormat }},
+	{Env: "HTTP_ADDR", Restart: true, Field: func(c *Config) interface{} { return &c.HTTPAddr }},
+}
+
+// Where a resolved setting came from, shown by --print-config
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+)
+
+// resolvedValue is the effective raw value of a setting
+type resolvedValue struct {
+	Value  string
+	Source string
+}
+
+// loadConfig loads the config file named by CONFIG_FILE, if any, and the environment
+func loadConfig() (*Config, error) {
+	return loadConfigFrom(getEnv("CONFIG_FILE", ""), os.LookupEnv)
+}
+
+// loadConfigFrom loads the config file at path (if not empty) and applies environment overrides
+// Every problem is reported at once, joined into a single error
+func loadConfigFrom(path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	values, resolveErr := resolveSettings(path, lookupEnv)
+	if values == nil {
+		return nil, resolveErr
+	}
+	config, err := buildConfig(values)
+	if err := errors.Join(resolveErr, err); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// resolveSettings returns the raw value of every setting: the default, overridden by the
+// config file, overridden by a non-empty environment variable
+func resolveSettings(path string, lookupEnv func(string) (string, bool)) (map[string]resolvedValue, error) {
+	file, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]resolvedValue, len(settings))
+	var errs []error
+	for _, s := range settings {
+		value := resolvedValue{Value: s.Default, Source: sourceDefault}
+		if node, ok := file[s.Key()]; ok {
+			raw, err := scalarOrList(node)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s %w", path, s.Key(), err))
+			}
+			value = resolvedValue{Value: raw, Source: sourceFile}
+			delete(file, s.Key())
+		}
+		if env, ok := lookupEnv(s.Env); ok && env != "" {
+			value = resolvedValue{Value: env, Source: sourceEnv}
+		}
+		values[s.Env] = value
+	}
+
+	// Anything left over is not a setting, most likely a typo
+	for key, node := range file {
+		errs = append(errs, fmt.Errorf("%s:%d: unknown setting %q", path, node.Line, key))
+	}
+	return values, errors.Join(errs...)
+}
+
+// readConfigFile parses the YAML mapping at path; an empty path means no file
+func readConfigFile(path string) (map[string]*yaml.Node, error) {
+	file := make(map[string]*yaml.Node)
+	if path == "" {
+		return file, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return file, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: config file must be a mapping of setting names to values", path)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i].Value
+		if _, exists := file[key]; exists {
+			return nil, fmt.Errorf("%s:%d: setting %q is set twice", path, root.Content[i].Line, key)
+		}
+		file[key] = root.Content[i+1]
+	}
+	return file, nil
+}
+
+// scalarOrList turns a YAML scalar, a list of scalars or a mapping of scalars into the string
+// form used by env vars
+func scalarOrList(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.MappingNode:
+		pairs := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Kind != yaml.ScalarNode || value.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("(line %d) must be a mapping of plain values", key.Line)
+			}
+			pairs = append(pairs, key.Value+"="+value.Value)
+		}
+		return strings.Join(pairs, ","), nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("(line %d) must be a list of plain values", item.Line)
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("(line %d) must be a value, a list or a mapping", node.Line)
+	}
+}
+
+// buildConfig parses the raw values into a Config and validates it
+func buildConfig(values map[string]resolvedValue) (*Config, error) {
+	config := &Config{}
+	var errs []error
+	for _, s := range settings {
+		if err := parseSetting(s.Field(config), values[s.Env].Value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Env, err))
+		}
+	}
+
+	config.LogFormat = strings.ToLower(config.LogFormat)
+	config.Transport = strings.ToLower(config.Transport)
+	errs = append(errs, validateConfig(config)...)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseSetting parses value into the Config field that field points to
+func parseSetting(field interface{}, value string) error {
+	switch field := field.(type) {
+	case *string:
+		*field = value
+	case *[]string:
+		*field = splitList(value)
+	case *map[string]string:
+		pairs := make(map[string]string)
+		for _, pair := range splitList(value) {
+			key, val, ok := strings.Cut(pair, "=")
+			key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+			if !ok || key == "" || val == "" {
+				return fmt.Errorf("%q must be key=value", pair)
+			}
+			pairs[key] = val
+		}
+		*field = pairs
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("must be a positive duration (e.g. 1m), got %q", value)
+		}
+		*field = d
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("must be a positive number, got %q", value)
+		}
+		*field = n
+	case **RateLimit:
+		limit, err := parseRateLimit(value)
+		if err != nil {
+			return err
+		}
+		*field = limit
+	default:
+		return fmt.Errorf("unsupported setting type %T", field)
+	}
+	return nil
+}
+
+// validateConfig checks values that parse but are not allowed, returning every problem found
+func validateConfig(config *Config) []error {
+	var errs []error
+	if config.LogFormat != LogFormatText && config.LogFormat != LogFormatJSON {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be %q or %q", LogFormatText, LogFormatJSON))
+	}
+	if config.Transport != TransportPubSub && config.Transport != TransportStreams {
+		errs = append(errs, fmt.Errorf("TRANSPORT must be %q or %q", TransportPubSub, TransportStreams))
+	}
+	if config.SlackToken == "" {
+		errs = append(errs, fmt.Errorf("SLACK_BOT_TOKEN must be set"))
+	}
+
+	// GITHUB_ORG is the default organization, and the first allowed one if only GITHUB_ORGS is set
+	if config.GithubOrg == "" && len(config.GithubOrgs) > 0 {
+		config.GithubOrg = config.GithubOrgs[0]
+	}
+	if config.GithubOrg == "" {
+		errs = append(errs, fmt.Errorf("GITHUB_ORG or GITHUB_ORGS must be set"))
+	} else if !config.AllowsOrg(config.GithubOrg) {
+		errs = append(errs, fmt.Errorf("GITHUB_ORG %q must be one of GITHUB_ORGS", config.GithubOrg))
+	}
+	for channel, org := range config.ChannelOrgs {
+		if !config.AllowsOrg(org) {
+			errs = append(errs, fmt.Errorf("CHANNEL_ORGS: %s maps to %q, which is not one of GITHUB_ORGS", channel, org))
+		}
+	}
+	// Logins end up in a shell command
+	for user, login := range config.GithubUsers {
+		if len(login) > MaxGithubLoginLength || !githubLoginPattern.MatchString(login) {
+			errs = append(errs, fmt.Errorf("GITHUB_USERS: %s maps to %q, which is not a GitHub login", user, login))
+		}
+	}
+	return errs
+}
+
+// Orgs returns the GitHub organizations repositories may be created in
+func (c *Config) Orgs() []string {
+	if len(c.GithubOrgs) == 0 {
+		return []string{c.GithubOrg}
+	}
+	return c.GithubOrgs
+}
+
+// AllowsOrg reports whether org is one of the configured organizations
+func (c *Config) AllowsOrg(org string) bool {
+	for _, allowed := range c.Orgs() {
+		if allowed == org {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultOrg returns the organization pre-selected for /new-repo run in channelID
+func (c *Config) DefaultOrg(channelID string) string {
+	if org, ok := c.ChannelOrgs[channelID]; ok {
+		return org
+	}
+	return c.GithubOrg
+}
+
+// printConfig writes the effective configuration as YAML, with secrets redacted
+// Each value is annotated with where it came from; problems are reported after it
+func printConfig(w io.Writer, path string, lookupEnv func(string) (string, bool)) error {
+	values, resolveErr := resolveSettings(path, lookupEnv)
+	if values == nil {
+		return resolveErr
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range settings {
+		value := values[s.Env]
+		shown := value.Value
+		if s.Secret && shown != "" {
+			shown = redacted
+		}
+		node := &yaml.Node{Kind: yaml.ScalarNode, Value: shown, LineComment: value.Source}
+		if shown == "" {
+			// Quote empty values so they read as "unset" rather than null
+			node.Style = yaml.DoubleQuotedStyle
+		}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s.Key()}, node)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	_, err := buildConfig(values)
+	return errors.Join(resolveErr, err)
+}
+
+// defaultConsumerName returns the hostname, which is unique per container replica
+func defaultConsumerName() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return "slashviberepo"
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEnv returns a lookupEnv function backed by a map
+func fakeEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+// writeConfigFile writes a config file to a temporary directory and returns its path
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+// TestLoadConfigLayering tests that the environment overrides the file, which overrides the defaults
+func TestLoadConfigLayering(t *testing.T) {
+	path := writeConfigFile(t, `
+slack_bot_token: xoxb-file
+github_org: file-org
+redis_addr: redis:6379
+allowed_team_ids: [T1, T2]
+approval_ttl: 2h
+rate_limit_user: 5/24h
+log_format: JSON
+`)
+	env := fakeEnv(map[string]string{
+		"GITHUB_ORG":            "env-org",
+		"REDIS_ADDR":            "",
+		"ALLOWED_APP_IDS":       "A1, A2",
+		"STREAM_CLAIM_MIN_IDLE": "30s",
+	})
+
+	config, err := loadConfigFrom(path, env)
+	if err != nil {
+		t.Fatalf("loadConfigFrom() failed: %v", err)
+	}
+
+	if config.GithubOrg != "env-org" {
+		t.Errorf("Expected the environment to override the file, got GithubOrg %q", config.GithubOrg)
+	}
+	if config.RedisAddr != "redis:6379" {
+		t.Errorf("Expected an empty env var to be ignored, got RedisAddr %q", config.RedisAddr)
+	}
+	if !reflect.DeepEqual(config.AllowedTeamIDs, []string{"T1", "T2"}) || !reflect.DeepEqual(config.AllowedAppIDs, []string{"A1", "A2"}) {
+		t.Errorf("Unexpected allow lists: teams %v, apps %v", config.AllowedTeamIDs, config.AllowedAppIDs)
+	}
+	if config.ApprovalTTL != 2*time.Hour || config.StreamClaimMinIdle != 30*time.Second {
+		t.Errorf("Unexpected durations: approval TTL %s, claim min idle %s", config.ApprovalTTL, config.StreamClaimMinIdle)
+	}
+	if config.RateLimitUser == nil || *config.RateLimitUser != (RateLimit{Limit: 5, Window: 24 * time.Hour}) || config.RateLimitOrg != nil {
+		t.Errorf("Unexpected rate limits: user %v, org %v", config.RateLimitUser, config.RateLimitOrg)
+	}
+	if config.LogFormat != LogFormatJSON {
+		t.Errorf("Expected LogFormat to be normalised to %q, got %q", LogFormatJSON, config.LogFormat)
+	}
+	if config.SlackChannelNewRepo != "#new-repo" || config.Transport != TransportPubSub {
+		t.Errorf("Expected defaults for unset values, got %q and %q", config.SlackChannelNewRepo, config.Transport)
+	}
+}
+
+// TestLoadConfigWithoutFile tests that the environment alone is enough
+func TestLoadConfigWithoutFile(t *testing.T) {
+	config, err := loadConfigFrom("", fakeEnv(map[string]string{"SLACK_BOT_TOKEN": "xoxb", "GITHUB_ORG": "org"}))
+	if err != nil {
+		t.Fatalf("loadConfigFrom() failed: %v", err)
+	}
+	if config.RedisAddr != "localhost:6379" || config.ApprovalTTL != 24*time.Hour || config.StreamMaxDeliveries != 5 {
+		t.Errorf("Expected defaults, got RedisAddr %q, ApprovalTTL %s and StreamMaxDeliveries %d", config.RedisAddr, config.ApprovalTTL, config.StreamMaxDeliveries)
+	}
+}
+
+// TestLoadConfigReportsAllErrors tests that every problem is reported at once
+func TestLoadConfigReportsAllErrors(t *testing.T) {
+	path := writeConfigFile(t, `
+redis_adr: typo:6379
+approval_ttl: soon
+stream_max_deliveries: 0
+rate_limit_org: 5
+transport: carrier-pigeon
+allowed_team_ids:
+  - nested: value
+`)
+
+	_, err := loadConfigFrom(path, fakeEnv(nil))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{
+		`unknown setting "redis_adr"`,
+		"APPROVAL_TTL",
+		"STREAM_MAX_DELIVERIES",
+		"RATE_LIMIT_ORG",
+		"TRANSPORT",
+		"allowed_team_ids",
+		"SLACK_BOT_TOKEN must be set",
+		"GITHUB_ORG or GITHUB_ORGS must be set",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to mention %q, got:\n%v", want, err)
+		}
+	}
+}
+
+// TestLoadConfigOrgs tests the allowed organizations and per-channel defaults
+func TestLoadConfigOrgs(t *testing.T) {
+	path := writeConfigFile(t, `
+slack_bot_token: xoxb
+github_orgs: [org-a, org-b]
+channel_orgs:
+  C_B: org-b
+`)
+
+	config, err := loadConfigFrom(path, fakeEnv(nil))
+	if err != nil {
+		t.Fatalf("loadConfigFrom() failed: %v", err)
+	}
+	if config.GithubOrg != "org-a" {
+		t.Errorf("Expected the first allowed organization to be the default, got %q", config.GithubOrg)
+	}
+	if !reflect.DeepEqual(config.Orgs(), []string{"org-a", "org-b"}) || config.AllowsOrg("org-c") {
+		t.Errorf("Unexpected organizations: %v", config.Orgs())
+	}
+	if config.DefaultOrg("C_B") != "org-b" || config.DefaultOrg("C_OTHER") != "org-a" {
+		t.Errorf("Unexpected default organizations: %q, %q", config.DefaultOrg("C_B"), config.DefaultOrg("C_OTHER"))
+	}
+
+	// A single organization needs no list
+	single := &Config{GithubOrg: "only-org"}
+	if !reflect.DeepEqual(single.Orgs(), []string{"only-org"}) {
+		t.Errorf("Expected GITHUB_ORG alone to be allowed, got %v", single.Orgs())
+	}
+
+	_, err = loadConfigFrom("", fakeEnv(map[string]string{
+		"SLACK_BOT_TOKEN": "xoxb",
+		"GITHUB_ORG":      "org-c",
+		"GITHUB_ORGS":     "org-a,org-b",
+		"CHANNEL_ORGS":    "C_A=org-a,C_X=org-x,broken",
+	}))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{`GITHUB_ORG "org-c" must be one of GITHUB_ORGS`, `"broken" must be key=value`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to mention %q, got:\n%v", want, err)
+		}
+	}
+}
+
+// TestLoadConfigGithubUsers tests the Slack user to GitHub login mapping
+func TestLoadConfigGithubUsers(t *testing.T) {
+	config, err := loadConfigFrom("", fakeEnv(map[string]string{
+		"SLACK_BOT_TOKEN": "xoxb",
+		"GITHUB_ORG":      "org-a",
+		"GITHUB_USERS":    "U1=octocat,U2=mona-lisa",
+	}))
+	if err != nil {
+		t.Fatalf("loadConfigFrom() failed: %v", err)
+	}
+	if !reflect.DeepEqual(config.GithubUsers, map[string]string{"U1": "octocat", "U2": "mona-lisa"}) {
+		t.Errorf("Unexpected GitHub users: %v", config.GithubUsers)
+	}
+
+	for _, login := range []string{"-octocat", "octo--cat", "octo cat;rm", strings.Repeat("a", 40)} {
+		_, err := loadConfigFrom("", fakeEnv(map[string]string{
+			"SLACK_BOT_TOKEN": "xoxb",
+			"GITHUB_ORG":      "org-a",
+			"GITHUB_USERS":    "U1=" + login,
+		}))
+		if err == nil || !strings.Contains(err.Error(), "is not a GitHub login") {
+			t.Errorf("Expected login %q to be rejected, got %v", login, err)
+		}
+	}
+}
+
+// TestLoadConfigInvalidFile tests files that cannot be read or are not a mapping
+func TestLoadConfigInvalidFile(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"Missing", filepath.Join(t.TempDir(), "missing.yaml")},
+		{"NotYAML", writeConfigFile(t, "redis_addr: [unclosed")},
+		{"NotMapping", writeConfigFile(t, "- redis_addr")},
+		{"Duplicate", writeConfigFile(t, "github_org: a\ngithub_org: b\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadConfigFrom(tt.path, fakeEnv(nil)); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+// TestPrintConfig tests that secrets are redacted and sources shown
+func TestPrintConfig(t *testing.T) {
+	path := writeConfigFile(t, "github_org: my-org\ngithub_token: ghp_secret\n")
+	env := fakeEnv(map[string]string{"SLACK_BOT_TOKEN": "xoxb-secret"})
+
+	var out bytes.Buffer
+	if err := printConfig(&out, path, env); err != nil {
+		t.Fatalf("printConfig() failed: %v", err)
+	}
+
+	output := out.String()
+	if strings.Contains(output, "secret") {
+		t.Errorf("Expected secrets to be redacted, got:\n%s", output)
+	}
+	for _, want := range []string{
+		"slack_bot_token: " + redacted + " # env",
+		"github_token: " + redacted + " # file",
+		"github_org: my-org # file",
+		"redis_addr: localhost:6379 # default",
+		`redis_password: "" # default`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+// TestPrintConfigInvalid tests that the configuration is printed before its problems are reported
+func TestPrintConfigInvalid(t *testing.T) {
+	var out bytes.Buffer
+	err := printConfig(&out, "", fakeEnv(nil))
+	if err == nil || !strings.Contains(err.Error(), "SLACK_BOT_TOKEN must be set") {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "redis_addr:") {
+		t.Errorf("Expected the configuration to be printed, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Processing stages recorded on dead letters
+const (
+	StageParseCommand        = "parse_command"
+	StageParseViewSubmission = "parse_view_submission"
+	StageHandleView          = "handle_view_submission"
+	StageParseBlockActions   = "parse_block_actions"
+	StageHandleBlockAction   = "handle_block_action"
+	StageRequestApproval     = "request_approval"
+	StageDeduplicate         = "deduplicate"
+	StageRateLimit           = "rate_limit"
+	StageQueuePoppit         = "queue_poppit"
+)
+
+// HandlerError describes why a payload could not be processed
+// Retryable errors are left for the transport to redeliver where it can
+type HandlerError struct {
+	Stage     string
+	Err       error
+	Retryable bool
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// DeadLetter is the envelope pushed to the dead-letter list for a payload that failed
+type DeadLetter struct {
+	Error     string    `json:"error"`
+	Stage     string    `json:"stage"`
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source"`
+	Payload   string    `json:"payload"`
+}
+
+// pushDeadLetter wraps payload in a DeadLetter and pushes it to the dead-letter list
+func pushDeadLetter(ctx context.Context, redisClient *redis.Client, config *Config, source, payload string, handlerErr *HandlerError) error {
+	envelope := DeadLetter{
+		Error:     handlerErr.Err.Error(),
+		Stage:     handlerErr.Stage,
+		Timestamp: time.Now().UTC(),
+		Source:    source,
+		Payload:   payload,
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+	return redisClient.RPush(ctx, config.RedisDeadLetterList, string(data)).Err()
+}
+
+// finishMessage acknowledges a handled message, dead-lettering it first if it failed
+// Retryable failures on the streams transport are left unacknowledged for redelivery
+// until they have been delivered STREAM_MAX_DELIVERIES times
+func finishMessage(ctx context.Context, logger *Logger, redisClient *redis.Client, config *Config, msg *Message, err error) {
+	if err != nil {
+		var handlerErr *HandlerError
+		if !errors.As(err, &handlerErr) {
+			handlerErr = &HandlerError{Stage: "unknown", Err: err}
+		}
+
+		if handlerErr.Retryable && config.Transport == TransportStreams && msg.Deliveries < int64(config.StreamMaxDeliveries) {
+			logger.Error("Failed to process message, leaving it for redelivery", "source", msg.Source, "stage", handlerErr.Stage, "deliveries", msg.Deliveries, "error", err)
+			return
+		}
+
+		logger.Error("Failed to process message, moving it to the dead-letter list", "source", msg.Source, "stage", handlerErr.Stage, "deliveries", msg.Deliveries, "dead_letter_list", config.RedisDeadLetterList, "error", err)
+		if dlErr := pushDeadLetter(ctx, redisClient, config, msg.Source, msg.Payload, handlerErr); dlErr != nil {
+			pushErrorsTotal.WithLabelValues(PushTargetDeadLetter).Inc()
+			// Keep the message pending rather than lose it
+			logger.Error("Failed to push dead letter", "error", dlErr)
+			return
+		}
+	}
+
+	if err := msg.Ack(ctx); err != nil {
+		logger.Error("Failed to acknowledge message", "source", msg.Source, "error", err)
+	}
+}
+
+// runDeadLetterCommand implements the "dead-letter" admin command and returns the exit code
+//
+//	slashviberepo dead-letter list [-n count]
+//	slashviberepo dead-letter replay [-n count]
+func runDeadLetterCommand(args []string, stdout, stderr io.Writer) int {
+	usage := func() {
+		fmt.Fprintln(stderr, "Usage: slashviberepo dead-letter <list|replay> [-n count]")
+		fmt.Fprintln(stderr, "  list    print dead letters as JSON lines, oldest first")
+		fmt.Fprintln(stderr, "  replay  republish dead letters to their source and remove them from the list")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	action := args[0]
+	flags := flag.NewFlagSet("dead-letter "+action, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	count := flags.Int("n", 0, "number of dead letters to process, 0 for all")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     config.RedisAddr,
+		Password: config.RedisPassword,
+	})
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	switch action {
+	case "list":
+		err = listDeadLetters(ctx, redisClient, config, *count, stdout)
+	case "replay":
+		err = replayDeadLetters(ctx, redisClient, config, *count, stdout)
+	default:
+		usage()
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "dead-letter %s failed: %v\n", action, err)
+		return 1
+	}
+	return 0
+}
+
+// listDeadLetters writes up to count dead letters (all if count is 0) to w, one per line
+func listDeadLetters(ctx context.Context, redisClient *redis.Client, config *Config, count int, w io.Writer) error {
+	entries, err := redisClient.LRange(ctx, config.RedisDeadLetterList, 0, int64(count)-1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.RedisDeadLetterList, err)
+	}
+	for _, entry := range entries {
+		fmt.Fprintln(w, entry)
+	}
+	return nil
+}
+
+// replayDeadLetters republishes up to count dead letters (all if count is 0) to their source
+// Each dead letter is removed only after it has been republished; entries that cannot be
+// replayed are reported and left in the list, so they do not block the ones behind them
+func replayDeadLetters(ctx context.Context, redisClient *redis.Client, config *Config, count int, w io.Writer) error {
+	replayed, skipped := 0, 0
+	for count == 0 || replayed < count {
+		// Skipped entries stay at the head of the list, so read past them
+		entry, err := redisClient.LIndex(ctx, config.RedisDeadLetterList, int64(skipped)).Result()
+		if errors.Is(err, redis.Nil) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", config.RedisDeadLetterList, err)
+		}
+
+		var deadLetter DeadLetter
+		if err := json.Unmarshal([]byte(entry), &deadLetter); err != nil {
+			fmt.Fprintf(w, "Skipped dead letter that is not valid JSON (%v): %s\n", err, entry)
+			skipped++
+			continue
+		}
+		if deadLetter.Source == "" {
+			fmt.Fprintf(w, "Skipped dead letter with no source: %s\n", entry)
+			skipped++
+			continue
+		}
+
+		if err := republish(ctx, redisClient, config, deadLetter.Source, deadLetter.Payload); err != nil {
+			return fmt.Errorf("failed to republish to %s: %w", deadLetter.Source, err)
+		}
+		if err := redisClient.LRem(ctx, config.RedisDeadLetterList, 1, entry).Err(); err != nil {
+			return fmt.Errorf("failed to remove replayed dead letter: %w", err)
+		}
+
+		fmt.Fprintf(w, "Replayed %s dead letter (%s) to %s\n", deadLetter.Stage, deadLetter.Error, deadLetter.Source)
+		replayed++
+	}
+
+	fmt.Fprintf(w, "Replayed %d dead letter(s)\n", replayed)
+	if skipped > 0 {
+		fmt.Fprintf(w, "Skipped %d dead letter(s) that cannot be replayed; they are still in %s\n", skipped, config.RedisDeadLetterList)
+	}
+	return nil
+}
+
+// republish sends payload back to source using the configured transport
+func republish(ctx context.Context, redisClient *redis.Client, config *Config, source, payload string) error {
+	if config.Transport == TransportStreams {
+		return redisClient.XAdd(ctx, &redis.XAddArgs{
+			Stream: source,
+			Values: map[string]interface{}{StreamPayloadField: payload},
+		}).Err()
+	}
+	return redisClient.Publish(ctx, source, payload).Err()
+}
+
+// runAdminCommand runs an admin subcommand if one was given and reports whether it did
+func runAdminCommand(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch args[0] {
+	case "dead-letter":
+		return runDeadLetterCommand(args[1:], os.Stdout, os.Stderr), true
+	case "healthcheck":
+		return runHealthcheckCommand(args[1:], os.Stdout, os.Stderr), true
+	}
+	return 0, false
+}
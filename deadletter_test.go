@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestHandlerError tests that handler errors describe their stage and unwrap to the cause
+func TestHandlerError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := error(&HandlerError{Stage: StageQueuePoppit, Err: cause, Retryable: true})
+
+	if err.Error() != "queue_poppit: connection refused" {
+		t.Errorf("Unexpected error message: %s", err.Error())
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Expected HandlerError to unwrap to its cause")
+	}
+}
+
+// TestRouterParseErrors tests that unparseable payloads are reported with their stage
+func TestRouterParseErrors(t *testing.T) {
+	router := NewCommandRouter(NewLogger("error"), &fakeViewResponder{}, nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		handle    func(context.Context, string) error
+		wantStage string
+	}{
+		{"Command", router.HandleMessage, StageParseCommand},
+		{"ViewSubmission", router.HandleViewSubmission, StageParseViewSubmission},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.handle(ctx, "{not json")
+			var handlerErr *HandlerError
+			if !errors.As(err, &handlerErr) {
+				t.Fatalf("Expected a *HandlerError, got %v", err)
+			}
+			if handlerErr.Stage != tt.wantStage || handlerErr.Retryable {
+				t.Errorf("Got stage %s (retryable: %v), want %s (not retryable)", handlerErr.Stage, handlerErr.Retryable, tt.wantStage)
+			}
+		})
+	}
+}
+
+// TestFinishMessageRetries tests that retryable stream failures are redelivered up to STREAM_MAX_DELIVERIES
+func TestFinishMessageRetries(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+
+	retryable := &HandlerError{Stage: StageRequestApproval, Err: errors.New("channel_not_found"), Retryable: true}
+	tests := []struct {
+		name           string
+		transport      string
+		deliveries     int64
+		err            error
+		wantDeadLetter bool
+	}{
+		{"Handled", TransportStreams, 1, nil, false},
+		{"FirstDelivery", TransportStreams, 1, retryable, false},
+		{"UnknownDeliveries", TransportStreams, 0, retryable, false},
+		{"LastDelivery", TransportStreams, 3, retryable, true},
+		{"PastLastDelivery", TransportStreams, 7, retryable, true},
+		{"NotRetryable", TransportStreams, 1, &HandlerError{Stage: StageParseCommand, Err: errors.New("bad json")}, true},
+		{"PubSub", TransportPubSub, 0, retryable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.FlushAll()
+			config := &Config{Transport: tt.transport, StreamMaxDeliveries: 3, RedisDeadLetterList: "dead-letters"}
+			acked := false
+			msg := &Message{Payload: "{}", Source: "view-submissions", Deliveries: tt.deliveries, ack: func(ctx context.Context) error {
+				acked = true
+				return nil
+			}}
+
+			finishMessage(context.Background(), NewLogger("error"), redisClient, config, msg, tt.err)
+
+			deadLettered := server.Exists("dead-letters")
+			if deadLettered != tt.wantDeadLetter {
+				t.Errorf("Expected dead-lettered %v, got %v", tt.wantDeadLetter, deadLettered)
+			}
+			// A message is acknowledged unless it is left for redelivery
+			if wantAck := tt.err == nil || tt.wantDeadLetter; acked != wantAck {
+				t.Errorf("Expected acknowledged %v, got %v", wantAck, acked)
+			}
+		})
+	}
+}
+
+// pushTestDeadLetters pushes raw entries to the dead-letter list
+func pushTestDeadLetters(t *testing.T, redisClient *redis.Client, config *Config, entries ...string) {
+	t.Helper()
+	for _, entry := range entries {
+		if err := redisClient.RPush(context.Background(), config.RedisDeadLetterList, entry).Err(); err != nil {
+			t.Fatalf("RPUSH failed: %v", err)
+		}
+	}
+}
+
+// deadLetterJSON returns a dead letter for payload from source
+func deadLetterJSON(t *testing.T, source, payload string) string {
+	t.Helper()
+	data, err := json.Marshal(DeadLetter{Error: "connection refused", Stage: StageQueuePoppit, Source: source, Payload: payload})
+	if err != nil {
+		t.Fatalf("Failed to marshal dead letter: %v", err)
+	}
+	return string(data)
+}
+
+// TestListDeadLetters tests that dead letters are printed oldest first, optionally limited
+func TestListDeadLetters(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+	config := &Config{RedisDeadLetterList: "dead-letters"}
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	if err := listDeadLetters(ctx, redisClient, config, 0, &out); err != nil || out.Len() != 0 {
+		t.Fatalf("Expected nothing for an empty list, got %q, %v", out.String(), err)
+	}
+
+	first, second := deadLetterJSON(t, "a", "1"), deadLetterJSON(t, "b", "2")
+	pushTestDeadLetters(t, redisClient, config, first, second)
+
+	out.Reset()
+	if err := listDeadLetters(ctx, redisClient, config, 0, &out); err != nil || out.String() != first+"\n"+second+"\n" {
+		t.Errorf("Expected both dead letters, got %q, %v", out.String(), err)
+	}
+	out.Reset()
+	if err := listDeadLetters(ctx, redisClient, config, 1, &out); err != nil || out.String() != first+"\n" {
+		t.Errorf("Expected only the oldest dead letter, got %q, %v", out.String(), err)
+	}
+}
+
+// TestReplayDeadLetters tests republishing with each transport, the -n limit and skipping bad entries
+func TestReplayDeadLetters(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+	ctx := context.Background()
+
+	t.Run("PubSub", func(t *testing.T) {
+		server.FlushAll()
+		config := &Config{Transport: TransportPubSub, RedisDeadLetterList: "dead-letters"}
+		pubsub := redisClient.Subscribe(ctx, "view-submissions")
+		defer pubsub.Close()
+		if _, err := pubsub.Receive(ctx); err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+
+		pushTestDeadLetters(t, redisClient, config, deadLetterJSON(t, "view-submissions", "1"), deadLetterJSON(t, "view-submissions", "2"))
+		var out bytes.Buffer
+		if err := replayDeadLetters(ctx, redisClient, config, 1, &out); err != nil {
+			t.Fatalf("replayDeadLetters() failed: %v", err)
+		}
+
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil || msg.Payload != "1" {
+			t.Errorf("Expected the oldest payload to be republished, got %+v, %v", msg, err)
+		}
+		if remaining, _ := server.List("dead-letters"); len(remaining) != 1 || !strings.Contains(remaining[0], `"payload":"2"`) {
+			t.Errorf("Expected only the newest dead letter to remain with -n 1, got %q", remaining)
+		}
+		if !strings.Contains(out.String(), "Replayed 1 dead letter(s)") {
+			t.Errorf("Unexpected output: %s", out.String())
+		}
+	})
+
+	t.Run("Streams", func(t *testing.T) {
+		server.FlushAll()
+		config := &Config{Transport: TransportStreams, RedisDeadLetterList: "dead-letters"}
+		pushTestDeadLetters(t, redisClient, config, deadLetterJSON(t, "slack-commands", "1"), deadLetterJSON(t, "view-submissions", "2"))
+
+		var out bytes.Buffer
+		if err := replayDeadLetters(ctx, redisClient, config, 0, &out); err != nil {
+			t.Fatalf("replayDeadLetters() failed: %v", err)
+		}
+		for stream, payload := range map[string]string{"slack-commands": "1", "view-submissions": "2"} {
+			entries, err := server.Stream(stream)
+			if err != nil || len(entries) != 1 || entries[0].Values[0] != StreamPayloadField || entries[0].Values[1] != payload {
+				t.Errorf("Expected %s to have one entry with payload %q, got %+v, %v", stream, payload, entries, err)
+			}
+		}
+		if server.Exists("dead-letters") {
+			t.Error("Expected every dead letter to be removed")
+		}
+	})
+
+	t.Run("SkipsBadEntries", func(t *testing.T) {
+		server.FlushAll()
+		config := &Config{Transport: TransportStreams, RedisDeadLetterList: "dead-letters"}
+		noSource := deadLetterJSON(t, "", "1")
+		pushTestDeadLetters(t, redisClient, config, "{not json", noSource, deadLetterJSON(t, "slack-commands", "2"))
+
+		var out bytes.Buffer
+		if err := replayDeadLetters(ctx, redisClient, config, 0, &out); err != nil {
+			t.Fatalf("replayDeadLetters() failed: %v", err)
+		}
+		if entries, _ := server.Stream("slack-commands"); len(entries) != 1 {
+			t.Errorf("Expected the entry behind the bad ones to be replayed, got %+v", entries)
+		}
+		if remaining, _ := server.List("dead-letters"); len(remaining) != 2 || remaining[0] != "{not json" || remaining[1] != noSource {
+			t.Errorf("Expected the bad entries to stay in the list, got %q", remaining)
+		}
+		if !strings.Contains(out.String(), "Skipped 2 dead letter(s)") {
+			t.Errorf("Expected the skipped entries to be reported, got: %s", out.String())
+		}
+
+		// Running again skips them again without failing
+		out.Reset()
+		if err := replayDeadLetters(ctx, redisClient, config, 0, &out); err != nil || !strings.Contains(out.String(), "Replayed 0 dead letter(s)") {
+			t.Errorf("Expected a second run to replay nothing, got %q, %v", out.String(), err)
+		}
+	})
+}
+
+// TestRunDeadLetterCommandUsage tests that invalid invocations print usage without connecting
+func TestRunDeadLetterCommandUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"NoAction", nil},
+		{"BadFlag", []string{"list", "-bogus"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runDeadLetterCommand(tt.args, &stdout, &stderr); code != 2 {
+				t.Errorf("Expected exit code 2, got %d", code)
+			}
+			if !strings.Contains(stderr.String(), "Usage") && !strings.Contains(stderr.String(), "flag provided but not defined") {
+				t.Errorf("Expected usage on stderr, got: %s", stderr.String())
+			}
+		})
+	}
+}
+
+// TestRunAdminCommand tests that only known subcommands are treated as admin commands
+func TestRunAdminCommand(t *testing.T) {
+	if _, ok := runAdminCommand(nil); ok {
+		t.Error("Expected no admin command without arguments")
+	}
+	if _, ok := runAdminCommand([]string{"serve"}); ok {
+		t.Error("Expected unknown arguments not to run an admin command")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// SubmissionKeyPrefix is the Redis key prefix used to deduplicate view submissions
+	SubmissionKeyPrefix = "slashviberepo:submission"
+	// SubmissionDedupTTL is how long a handled view submission is remembered
+	SubmissionDedupTTL = 24 * time.Hour
+)
+
+// submissionKey identifies a view submission by its view ID, view hash and target repository
+// Slack retries and relay replays of the same submission share all three
+func submissionKey(submission *ViewSubmissionPayload, repoFullName string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", SubmissionKeyPrefix, submission.View.ID, submission.View.Hash, repoFullName)
+}
+
+// claimSubmission records the submission with SET NX and reports whether this is the first copy
+// Submissions without a view ID cannot be deduplicated and are always claimed
+func claimSubmission(ctx context.Context, redisClient *redis.Client, submission *ViewSubmissionPayload, repoFullName string) (bool, error) {
+	if submission.View.ID == "" {
+		return true, nil
+	}
+	return redisClient.SetNX(ctx, submissionKey(submission, repoFullName), time.Now().UTC().Format(time.RFC3339), SubmissionDedupTTL).Result()
+}
+
+// releaseSubmission forgets a claimed submission so a retry of it is not treated as a duplicate
+func releaseSubmission(ctx context.Context, redisClient *redis.Client, submission *ViewSubmissionPayload, repoFullName string) error {
+	if submission.View.ID == "" {
+		return nil
+	}
+	return redisClient.Del(ctx, submissionKey(submission, repoFullName)).Err()
+}
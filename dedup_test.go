@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// TestSubmissionKey tests that the key combines the view ID, view hash and repository
+func TestSubmissionKey(t *testing.T) {
+	var submission ViewSubmissionPayload
+	payload := `{"type":"view_submission","view":{"id":"V123","hash":"1712345678.abcdef","callback_id":"create_github_repo_modal"}}`
+	if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+
+	got := submissionKey(&submission, "org/repo")
+	want := "slashviberepo:submission:V123:1712345678.abcdef:org/repo"
+	if got != want {
+		t.Errorf("submissionKey() = %s, want %s", got, want)
+	}
+
+	if other := submissionKey(&submission, "org/other"); other == got {
+		t.Error("Expected different repositories to produce different keys")
+	}
+}
+
+// TestClaimSubmissionWithoutViewID tests that submissions without a view ID are never deduplicated
+func TestClaimSubmissionWithoutViewID(t *testing.T) {
+	submission := &ViewSubmissionPayload{}
+
+	// No Redis access is needed, so a nil client is safe here
+	claimed, err := claimSubmission(context.Background(), nil, submission, "org/repo")
+	if err != nil || !claimed {
+		t.Errorf("claimSubmission() = %v, %v, want true, nil", claimed, err)
+	}
+	if err := releaseSubmission(context.Background(), nil, submission, "org/repo"); err != nil {
+		t.Errorf("releaseSubmission() = %v, want nil", err)
+	}
+}
+
+// TestHandleViewSubmissionDuplicate tests that a repeated submission is only queued once
+func TestHandleViewSubmissionDuplicate(t *testing.T) {
+	service, _ := newTestService(t, &Config{GithubOrg: "my-org"})
+	queue := service.Queue.(*fakeQueue)
+	ctx := context.Background()
+
+	var submission ViewSubmissionPayload
+	payload := `{"type":"view_submission","user":{"id":"U1"},"view":{"id":"V1","hash":"1712345678.abcdef","callback_id":"create_github_repo_modal","state":{"values":{
+		"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"tool"}}}}}}`
+	if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		response, err := service.handleViewSubmission(ctx, &submission)
+		if err != nil || response != nil {
+			t.Fatalf("Submission %d: expected no response and no error, got %+v, %v", i+1, response, err)
+		}
+	}
+	if got := len(queue.Commands()); got != 1 {
+		t.Fatalf("Expected one Poppit command for a repeated submission, got %d", got)
+	}
+
+	// A replay after the repository has been created is still a duplicate, not a name collision
+	service.RepoChecker = &fakeRepoChecker{existing: map[string]bool{"my-org/tool": true}}
+	if response, err := service.handleViewSubmission(ctx, &submission); err != nil || response != nil {
+		t.Fatalf("Expected a replay of a created repository to be acknowledged, got %+v, %v", response, err)
+	}
+	service.RepoChecker = &fakeRepoChecker{}
+
+	// A submission that failed to queue can be retried
+	submission.View.ID = "V2"
+	queue.err = errors.New("connection refused")
+	if _, err := service.handleViewSubmission(ctx, &submission); err == nil {
+		t.Fatal("Expected the queue error to be returned")
+	}
+	queue.err = nil
+	if _, err := service.handleViewSubmission(ctx, &submission); err != nil {
+		t.Fatalf("handleViewSubmission() failed: %v", err)
+	}
+	if got := len(queue.Commands()); got != 2 {
+		t.Errorf("Expected the retry to be queued, got %d commands", got)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadingFile holds a value loaded from a file and reloads it when the file's modification
+// time changes; if the new version cannot be loaded the previous value stays in force
+type reloadingFile[T any] struct {
+	logger *Logger
+	// kind names the file in errors and logs, e.g. "policy" or "templates"
+	kind string
+	path string
+	load func(path string) (T, error)
+
+	mu      sync.Mutex
+	value   T
+	modTime time.Time
+}
+
+// newReloadingFile loads the file at path, failing if it is missing or invalid
+func newReloadingFile[T any](logger *Logger, kind, path string, load func(path string) (T, error)) (*reloadingFile[T], error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file: %w", kind, err)
+	}
+	value, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &reloadingFile[T]{logger: logger, kind: kind, path: path, load: load, value: value, modTime: info.ModTime()}, nil
+}
+
+// Get reloads the file if it has changed and returns the value in force
+func (f *reloadingFile[T]) Get() T {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		f.logger.Error("Failed to check file, keeping the current version", "file", f.kind, "path", f.path, "error", err)
+		return f.value
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return f.value
+	}
+
+	// Do not retry until the file changes again, whether or not it loads
+	f.modTime = info.ModTime()
+	value, err := f.load(f.path)
+	if err != nil {
+		f.logger.Error("Failed to reload file, keeping the current version", "file", f.kind, "path", f.path, "error", err)
+		return f.value
+	}
+	f.logger.Info("Reloaded file", "file", f.kind, "path", f.path)
+	f.value = value
+	return f.value
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReloadingFile tests when the file is loaded again and what is served meanwhile
+func TestReloadingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	modTime := time.Now().Add(-time.Hour)
+	writePolicy(t, path, "one", modTime)
+
+	loads := 0
+	load := func(path string) (string, error) {
+		loads++
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(string(data), "bad") {
+			return "", errors.New("invalid")
+		}
+		return string(data), nil
+	}
+
+	file, err := newReloadingFile(NewLogger("error"), "test", path, load)
+	if err != nil {
+		t.Fatalf("newReloadingFile() failed: %v", err)
+	}
+	if got := file.Get(); got != "one" || loads != 1 {
+		t.Fatalf("Expected %q from a single load, got %q after %d loads", "one", got, loads)
+	}
+
+	modTime = modTime.Add(time.Minute)
+	writePolicy(t, path, "two", modTime)
+	if got := file.Get(); got != "two" || loads != 2 {
+		t.Errorf("Expected the changed file to be loaded once, got %q after %d loads", got, loads)
+	}
+
+	// A broken version is tried once, then ignored until the file changes again
+	modTime = modTime.Add(time.Minute)
+	writePolicy(t, path, "bad", modTime)
+	for i := 0; i < 2; i++ {
+		if got := file.Get(); got != "two" {
+			t.Errorf("Expected the previous value to stay in force, got %q", got)
+		}
+	}
+	if loads != 3 {
+		t.Errorf("Expected the broken version to be loaded once, got %d loads", loads)
+	}
+
+	// A file that disappears keeps the last good value
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if got := file.Get(); got != "two" {
+		t.Errorf("Expected the previous value without a file, got %q", got)
+	}
+
+	if _, err := newReloadingFile(NewLogger("error"), "test", path, load); err == nil || !strings.Contains(err.Error(), "failed to read test file") {
+		t.Errorf("Expected a missing file to fail at startup, got %v", err)
+	}
+	writePolicy(t, path, "bad", time.Now())
+	if _, err := newReloadingFile(NewLogger("error"), "test", path, load); err == nil {
+		t.Error("Expected an invalid file to fail at startup")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultGitHubAPIURL is the base URL of the GitHub REST API
+	DefaultGitHubAPIURL = "https://api.github.com"
+	// GitHubAPITimeout bounds GitHub API calls made while Slack waits for a view submission response
+	GitHubAPITimeout = 2 * time.Second
+)
+
+// RepoChecker reports whether a GitHub repository already exists
+type RepoChecker interface {
+	RepoExists(ctx context.Context, repoFullName string) (bool, error)
+}
+
+// GitHubRepoChecker checks for repositories with the GitHub REST API
+type GitHubRepoChecker struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewGitHubRepoChecker creates a GitHubRepoChecker for the API at baseURL
+// The token is optional but needed to see private repositories
+func NewGitHubRepoChecker(baseURL, token string) *GitHubRepoChecker {
+	return &GitHubRepoChecker{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		httpClient: &http.Client{
+			Timeout: GitHubAPITimeout,
+			// A redirect means the repository was renamed or transferred, so the old name is free
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// RepoExists calls GET /repos/{owner}/{repo} and reports whether it was found
+func (c *GitHubRepoChecker) RepoExists(ctx context.Context, repoFullName string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/repos/%s", c.baseURL, repoFullName), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to check repository %s: %w", repoFullName, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode >= 300 && resp.StatusCode < 400:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status checking repository %s: %s", repoFullName, resp.Status)
+	}
+}
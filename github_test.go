@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+// TestGitHubRepoCheckerRepoExists tests the result for each kind of API response
+func TestGitHubRepoCheckerRepoExists(t *testing.T) {
+	tests := []struct {
+		name       string
+		repo       string
+		wantExists bool
+		wantErr    bool
+	}{
+		{"Exists", "org/taken", true, false},
+		{"NotFound", "org/free", false, false},
+		{"Renamed", "org/renamed", false, false},
+		{"RateLimited", "org/limited", false, true},
+		{"ServerError", "org/broken", false, true},
+	}
+
+	var gotAuth, gotAccept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotAccept = r.Header.Get("Accept")
+		switch r.URL.Path {
+		case "/repos/org/taken", "/repos/org/new-name":
+			w.WriteHeader(http.StatusOK)
+		case "/repos/org/renamed":
+			http.Redirect(w, r, "/repos/org/new-name", http.StatusMovedPermanently)
+		case "/repos/org/limited":
+			w.WriteHeader(http.StatusForbidden)
+		case "/repos/org/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	checker := NewGitHubRepoChecker(server.URL+"/", "secret-token")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exists, err := checker.RepoExists(context.Background(), tt.repo)
+			if exists != tt.wantExists || (err != nil) != tt.wantErr {
+				t.Errorf("RepoExists(%s) = %v, %v, want %v, error %v", tt.repo, exists, err, tt.wantExists, tt.wantErr)
+			}
+		})
+	}
+
+	if gotAuth != "Bearer secret-token" {
+		t.Errorf("Expected bearer token to be sent, got %q", gotAuth)
+	}
+	if gotAccept != "application/vnd.github+json" {
+		t.Errorf("Expected GitHub Accept header, got %q", gotAccept)
+	}
+}
+
+// TestGitHubRepoCheckerWithoutToken tests that no Authorization header is sent without a token
+func TestGitHubRepoCheckerWithoutToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("Expected no Authorization header, got %q", auth)
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	exists, err := NewGitHubRepoChecker(server.URL, "").RepoExists(context.Background(), "org/repo")
+	if exists || err != nil {
+		t.Errorf("RepoExists() = %v, %v, want false, nil", exists, err)
+	}
+}
+
+// fakeRepoChecker reports the configured repositories as existing
+type fakeRepoChecker struct {
+	existing map[string]bool
+	err      error
+}
+
+func (f *fakeRepoChecker) RepoExists(ctx context.Context, repoFullName string) (bool, error) {
+	return f.existing[repoFullName], f.err
+}
+
+// TestHandleViewSubmissionExistingRepo tests that a taken name is reported in the modal
+func TestHandleViewSubmissionExistingRepo(t *testing.T) {
+	var submission ViewSubmissionPayload
+	payload := `{"type":"view_submission","view":{"id":"V1","callback_id":"create_github_repo_modal","state":{"values":{
+		"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"taken"}}}}}}`
+	if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+
+	service, server := newTestService(t, &Config{GithubOrg: "my-org"})
+	service.RepoChecker = &fakeRepoChecker{existing: map[string]bool{"my-org/taken": true}}
+	response, err := service.handleViewSubmission(context.Background(), &submission)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response == nil || response.ResponseAction != slack.RAErrors {
+		t.Fatalf("Expected an errors response, got %+v", response)
+	}
+	if msg := response.Errors["repo-name"]; !strings.Contains(msg, "my-org/taken already exists") {
+		t.Errorf("Unexpected repo-name error: %q", msg)
+	}
+	// The submission is not remembered, so it is not mistaken for a duplicate if resubmitted
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("Expected nothing left in Redis, got %v", keys)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RedisPingInterval is how often Redis is pinged for the readiness check
+	RedisPingInterval = 10 * time.Second
+	// RedisPingMaxAge is how old the last successful Redis ping may be before the service is not ready
+	RedisPingMaxAge = 3 * RedisPingInterval
+	// SlackAuthInterval is how often the Slack token is checked with auth.test
+	SlackAuthInterval = 5 * time.Minute
+	// healthCheckTimeout bounds each Redis ping, auth.test call and healthcheck request
+	healthCheckTimeout = 5 * time.Second
+)
+
+// CheckResult is the outcome of a single readiness check
+type CheckResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// ReadinessReport is the body served by /readyz
+type ReadinessReport struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// HealthChecker tracks whether the service can do useful work
+// Subscriptions are checked live; Redis and Slack are checked periodically by Run
+type HealthChecker struct {
+	pingRedis     func(ctx context.Context) error
+	testSlackAuth func(ctx context.Context) error
+	now           func() time.Time
+
+	mu            sync.Mutex
+	subscribers   map[string]Subscriber
+	lastPing      time.Time
+	lastPingError error
+	slackAuthErr  error
+	slackChecked  bool
+}
+
+// NewHealthChecker creates a HealthChecker using the given Redis ping and Slack auth.test calls
+func NewHealthChecker(pingRedis, testSlackAuth func(ctx context.Context) error) *HealthChecker {
+	return &HealthChecker{
+		pingRedis:     pingRedis,
+		testSlackAuth: testSlackAuth,
+		now:           time.Now,
+		subscribers:   make(map[string]Subscriber),
+	}
+}
+
+// AddSubscriber includes a subscription in the readiness check under name
+func (h *HealthChecker) AddSubscriber(name string, sub Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[name] = sub
+}
+
+// RemoveSubscriber drops the subscription named name from the readiness check
+func (h *HealthChecker) RemoveSubscriber(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, name)
+}
+
+// Run pings Redis and checks the Slack token until ctx is cancelled
+func (h *HealthChecker) Run(ctx context.Context, logger *Logger) {
+	pingTicker := time.NewTicker(RedisPingInterval)
+	defer pingTicker.Stop()
+	slackTicker := time.NewTicker(SlackAuthInterval)
+	defer slackTicker.Stop()
+
+	h.checkRedis(ctx, logger)
+	h.checkSlack(ctx, logger)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pingTicker.C:
+			h.checkRedis(ctx, logger)
+		case <-slackTicker.C:
+			h.checkSlack(ctx, logger)
+		}
+	}
+}
+
+func (h *HealthChecker) checkRedis(ctx context.Context, logger *Logger) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	err := h.pingRedis(ctx)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		if h.lastPingError == nil {
+			logger.Warn("Redis ping failed", "error", err)
+		}
+	} else {
+		if h.lastPingError != nil {
+			logger.Info("Redis ping succeeded again")
+		}
+		h.lastPing = h.now()
+	}
+	h.lastPingError = err
+}
+
+func (h *HealthChecker) checkSlack(ctx context.Context, logger *Logger) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	err := h.testSlackAuth(ctx)
+	if err != nil {
+		slackAPIErrorsTotal.WithLabelValues(SlackMethodAuthTest).Inc()
+		logger.Warn("Slack auth.test failed", "error", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.slackAuthErr = err
+	h.slackChecked = true
+}
+
+// Readiness reports the state of every check
+func (h *HealthChecker) Readiness() *ReadinessReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	report := &ReadinessReport{Ready: true, Checks: make(map[string]CheckResult)}
+	set := func(name string, err error) {
+		result := CheckResult{OK: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+			report.Ready = false
+		}
+		report.Checks[name] = result
+	}
+
+	if len(h.subscribers) == 0 {
+		set("subscriptions", fmt.Errorf("not subscribed yet"))
+	}
+	for name, sub := range h.subscribers {
+		var err error
+		if !sub.Subscribed() {
+			err = fmt.Errorf("not subscribed")
+		}
+		set("subscription:"+name, err)
+	}
+
+	switch {
+	case h.lastPing.IsZero() && h.lastPingError != nil:
+		set("redis", h.lastPingError)
+	case h.lastPing.IsZero():
+		set("redis", fmt.Errorf("not pinged yet"))
+	case h.now().Sub(h.lastPing) > RedisPingMaxAge:
+		set("redis", fmt.Errorf("no successful ping since %s", h.lastPing.UTC().Format(time.RFC3339)))
+	default:
+		set("redis", nil)
+	}
+
+	if !h.slackChecked {
+		set("slack_auth", fmt.Errorf("not checked yet"))
+	} else {
+		set("slack_auth", h.slackAuthErr)
+	}
+
+	return report
+}
+
+// handleHealthz reports that the process is running and serving HTTP
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz serves the readiness report, with 503 if any check fails
+func (h *HealthChecker) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := h.Readiness()
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// runHealthcheckCommand implements the "healthcheck" admin command for container healthchecks
+// The runtime image has no shell or curl, so the binary checks itself
+//
+//	slashviberepo healthcheck [-path /readyz]
+func runHealthcheckCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := flags.String("path", "/readyz", "endpoint to check")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	values, err := resolveSettings(getEnv("CONFIG_FILE", ""), os.LookupEnv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	addr := values["HTTP_ADDR"].Value
+	if addr == "" {
+		fmt.Fprintln(stderr, "HTTP_ADDR is not set, so there is no endpoint to check")
+		return 1
+	}
+
+	client := &http.Client{Timeout: healthCheckTimeout}
+	resp, err := client.Get(healthcheckURL(addr, *path))
+	if err != nil {
+		fmt.Fprintf(stderr, "healthcheck failed: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	io.Copy(stdout, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(stderr, "healthcheck failed: %s returned %s\n", *path, resp.Status)
+		return 1
+	}
+	return 0
+}
+
+// healthcheckURL builds the local URL for path on the listener address addr
+func healthcheckURL(addr, path string) string {
+	// A listener on all interfaces is reached on the loopback address
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
+	} else if strings.HasPrefix(addr, "0.0.0.0:") {
+		addr = "127.0.0.1" + strings.TrimPrefix(addr, "0.0.0.0")
+	}
+	return "http://" + addr + path
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSubscriber is a Subscriber whose subscription state is set by the test
+type fakeSubscriber struct {
+	subscribed bool
+}
+
+func (f *fakeSubscriber) Messages() <-chan *Message { return nil }
+func (f *fakeSubscriber) Close() error              { return nil }
+func (f *fakeSubscriber) Subscribed() bool          { return f.subscribed }
+
+// TestHealthCheckerReadiness tests that every check has to pass for the service to be ready
+func TestHealthCheckerReadiness(t *testing.T) {
+	var pingErr, authErr error
+	health := NewHealthChecker(
+		func(ctx context.Context) error { return pingErr },
+		func(ctx context.Context) error { return authErr },
+	)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	health.now = func() time.Time { return now }
+	logger := NewLogger("error")
+	ctx := context.Background()
+
+	// Nothing has been checked yet
+	if report := health.Readiness(); report.Ready {
+		t.Errorf("Expected not ready before any checks, got %+v", report)
+	}
+
+	commands := &fakeSubscriber{subscribed: true}
+	health.AddSubscriber("slack-commands", commands)
+	health.checkRedis(ctx, logger)
+	health.checkSlack(ctx, logger)
+	if report := health.Readiness(); !report.Ready {
+		t.Errorf("Expected ready, got %+v", report)
+	}
+
+	tests := []struct {
+		name    string
+		breakIt func()
+		check   string
+	}{
+		{"SubscriptionLost", func() { commands.subscribed = false }, "subscription:slack-commands"},
+		{"SlackAuthFailed", func() { authErr = errors.New("invalid_auth"); health.checkSlack(ctx, logger) }, "slack_auth"},
+		{"RedisPingStale", func() {
+			pingErr = errors.New("connection refused")
+			health.checkRedis(ctx, logger)
+			now = now.Add(RedisPingMaxAge + time.Second)
+		}, "redis"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.breakIt()
+			report := health.Readiness()
+			if report.Ready {
+				t.Errorf("Expected not ready, got %+v", report)
+			}
+			if result := report.Checks[tt.check]; result.OK || result.Error == "" {
+				t.Errorf("Expected %s to fail with an error, got %+v", tt.check, result)
+			}
+		})
+	}
+}
+
+// TestHealthCheckerRedisPingWithinMaxAge tests that a single failed ping does not make the service unready
+func TestHealthCheckerRedisPingWithinMaxAge(t *testing.T) {
+	var pingErr error
+	health := NewHealthChecker(
+		func(ctx context.Context) error { return pingErr },
+		func(ctx context.Context) error { return nil },
+	)
+	logger := NewLogger("error")
+	ctx := context.Background()
+
+	health.checkRedis(ctx, logger)
+	pingErr = errors.New("timeout")
+	health.checkRedis(ctx, logger)
+
+	if result := health.Readiness().Checks["redis"]; !result.OK {
+		t.Errorf("Expected redis check to pass within %s of the last ping, got %+v", RedisPingMaxAge, result)
+	}
+}
+
+// TestHealthEndpoints tests the status codes served by /healthz and /readyz
+func TestHealthEndpoints(t *testing.T) {
+	health := NewHealthChecker(
+		func(ctx context.Context) error { return nil },
+		func(ctx context.Context) error { return nil },
+	)
+	server := httptest.NewServer(newHTTPHandler(health))
+	defer server.Close()
+
+	get := func(path string) (int, []byte) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		return resp.StatusCode, body.Bytes()
+	}
+
+	if status, _ := get("/healthz"); status != http.StatusOK {
+		t.Errorf("Expected /healthz to return 200, got %d", status)
+	}
+	if status, _ := get("/readyz"); status != http.StatusServiceUnavailable {
+		t.Errorf("Expected /readyz to return 503 before any checks, got %d", status)
+	}
+
+	health.AddSubscriber("slack-commands", &fakeSubscriber{subscribed: true})
+	health.checkRedis(context.Background(), NewLogger("error"))
+	health.checkSlack(context.Background(), NewLogger("error"))
+
+	status, body := get("/readyz")
+	if status != http.StatusOK {
+		t.Errorf("Expected /readyz to return 200, got %d: %s", status, body)
+	}
+	var report ReadinessReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		t.Fatalf("Failed to unmarshal readiness report: %v", err)
+	}
+	for _, check := range []string{"subscription:slack-commands", "redis", "slack_auth"} {
+		if !report.Checks[check].OK {
+			t.Errorf("Expected %s check in report, got %+v", check, report.Checks)
+		}
+	}
+}
+
+// TestHealthcheckURL tests that listener addresses are turned into local URLs
+func TestHealthcheckURL(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{":9090", "http://127.0.0.1:9090/readyz"},
+		{"0.0.0.0:9090", "http://127.0.0.1:9090/readyz"},
+		{"localhost:8080", "http://localhost:8080/readyz"},
+	}
+
+	for _, tt := range tests {
+		if got := healthcheckURL(tt.addr, "/readyz"); got != tt.want {
+			t.Errorf("healthcheckURL(%q) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+}
+
+// TestRunHealthcheckCommand tests that the exit code follows the endpoint status
+func TestRunHealthcheckCommand(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	t.Setenv("HTTP_ADDR", strings.TrimPrefix(server.URL, "http://"))
+
+	var stdout, stderr bytes.Buffer
+	if code := runHealthcheckCommand(nil, &stdout, &stderr); code != 0 {
+		t.Errorf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	status = http.StatusServiceUnavailable
+	if code := runHealthcheckCommand([]string{"-path", "/readyz"}, &stdout, &stderr); code != 1 {
+		t.Errorf("Expected exit code 1 for 503, got %d", code)
+	}
+
+	t.Setenv("HTTP_ADDR", "")
+	if code := runHealthcheckCommand(nil, &stdout, &stderr); code != 1 {
+		t.Errorf("Expected exit code 1 without HTTP_ADDR, got %d", code)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LogLevel represents the logging level
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+const (
+	// LogFormatText writes "[LEVEL] message key=value" lines
+	LogFormatText = "text"
+	// LogFormatJSON writes one JSON object per line
+	LogFormatJSON = "json"
+)
+
+// slogLevel converts a LogLevel to the equivalent slog.Level
+func (l LogLevel) slogLevel() slog.Level {
+	switch l {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Logger provides structured logging with log levels
+// Messages are constant strings and details are passed as key-value pairs,
+// e.g. logger.Info("Opened modal", "user_id", cmd.UserID)
+type Logger struct {
+	level  LogLevel
+	logger *slog.Logger
+}
+
+// NewLogger creates a new text Logger with the specified level
+func NewLogger(levelStr string) *Logger {
+	return NewLoggerWithFormat(levelStr, LogFormatText)
+}
+
+// NewLoggerWithFormat creates a new Logger with the specified level and format ("text" or "json")
+// Output goes to the writer of the standard log package
+func NewLoggerWithFormat(levelStr, format string) *Logger {
+	var level LogLevel
+	switch strings.ToLower(levelStr) {
+	case "debug":
+		level = LogLevelDebug
+	case "info":
+		level = LogLevelInfo
+	case "warn", "warning":
+		level = LogLevelWarn
+	case "error":
+		level = LogLevelError
+	default:
+		level = LogLevelInfo
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, LogFormatJSON) {
+		handler = slog.NewJSONHandler(log.Writer(), &slog.HandlerOptions{
+			Level:       level.slogLevel(),
+			ReplaceAttr: replaceJSONAttr,
+		})
+	} else {
+		handler = &textHandler{level: level.slogLevel()}
+	}
+
+	return &Logger{level: level, logger: slog.New(handler)}
+}
+
+// replaceJSONAttr names the built-in JSON fields timestamp, level and msg
+func replaceJSONAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		a.Key = "timestamp"
+	case slog.LevelKey:
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(strings.ToLower(levelLabel(level)))
+		}
+	}
+	return a
+}
+
+// With returns a Logger that adds the key-value pairs to every message
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{level: l.level, logger: l.logger.With(args...)}
+}
+
+// Debug logs a debug message
+func (l *Logger) Debug(msg string, args ...any) {
+	l.logger.Debug(msg, args...)
+}
+
+// Info logs an info message
+func (l *Logger) Info(msg string, args ...any) {
+	l.logger.Info(msg, args...)
+}
+
+// Warn logs a warning message
+func (l *Logger) Warn(msg string, args ...any) {
+	l.logger.Warn(msg, args...)
+}
+
+// Error logs an error message
+func (l *Logger) Error(msg string, args ...any) {
+	l.logger.Error(msg, args...)
+}
+
+// Fatal logs a fatal error message and exits
+// Fatal messages are always logged regardless of level as they indicate program termination
+func (l *Logger) Fatal(msg string, args ...any) {
+	l.logger.Log(context.Background(), slogLevelFatal, msg, args...)
+	os.Exit(1)
+}
+
+// slogLevelFatal sits above every configurable level so fatal messages are never filtered
+const slogLevelFatal = slog.Level(12)
+
+// textHandler is a slog.Handler producing the service's "[LEVEL] message key=value" lines
+type textHandler struct {
+	level slog.Level
+	attrs []slog.Attr
+	group string
+}
+
+func (h *textHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(ctx context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString("[")
+	b.WriteString(levelLabel(r.Level))
+	b.WriteString("] ")
+	b.WriteString(r.Message)
+
+	for _, attr := range h.attrs {
+		writeTextAttr(&b, "", attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		writeTextAttr(&b, h.group, attr)
+		return true
+	})
+
+	log.Print(b.String())
+	return nil
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	qualified := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	qualified = append(qualified, h.attrs...)
+	for _, attr := range attrs {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		qualified = append(qualified, attr)
+	}
+	return &textHandler{level: h.level, attrs: qualified, group: h.group}
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	group := name
+	if h.group != "" {
+		group = h.group + "." + name
+	}
+	return &textHandler{level: h.level, attrs: h.attrs, group: group}
+}
+
+// levelLabel returns the bracketed label used for a level in text output
+func levelLabel(level slog.Level) string {
+	switch {
+	case level >= slogLevelFatal:
+		return "FATAL"
+	case level >= slog.LevelError:
+		return "ERROR"
+	case level >= slog.LevelWarn:
+		return "WARN"
+	case level >= slog.LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// writeTextAttr appends " key=value", quoting values that contain spaces or quotes
+func writeTextAttr(b *strings.Builder, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	key := attr.Key
+	if group != "" {
+		key = group + "." + key
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, groupAttr := range attr.Value.Group() {
+			writeTextAttr(b, key, groupAttr)
+		}
+		return
+	}
+
+	value := fmt.Sprint(attr.Value.Any())
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+
+	b.WriteString(" ")
+	b.WriteString(key)
+	b.WriteString("=")
+	b.WriteString(value)
+}
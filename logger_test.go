@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureLog redirects the standard logger to a buffer for the duration of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	flags := log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+// TestLoggerTextFormat tests that text output keeps the "[LEVEL] message" prefix and adds key=value pairs
+func TestLoggerTextFormat(t *testing.T) {
+	buf := captureLog(t)
+
+	logger := NewLogger("info").With("correlation_id", "abc123")
+	logger.Info("Pushed Poppit command", "repo", "org/repo", "error", errors.New("no space left"))
+
+	want := `[INFO] Pushed Poppit command correlation_id=abc123 repo=org/repo error="no space left"` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Unexpected text output:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+// TestLoggerJSONFormat tests that JSON output has one object per line with level, msg, timestamp and fields
+func TestLoggerJSONFormat(t *testing.T) {
+	buf := captureLog(t)
+
+	logger := NewLoggerWithFormat("debug", LogFormatJSON)
+	logger.Debug("Processing command", "command", "/new-repo", "user_id", "U123")
+	logger.With("callback_id", "create_github_repo_modal").Warn("Invalid repository name", "repo", "org/bad name")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON lines, got %d: %s", len(lines), buf.String())
+	}
+
+	tests := []map[string]string{
+		{"level": "debug", "msg": "Processing command", "command": "/new-repo", "user_id": "U123"},
+		{"level": "warn", "msg": "Invalid repository name", "callback_id": "create_github_repo_modal", "repo": "org/bad name"},
+	}
+	for i, want := range tests {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatalf("Line %d is not valid JSON: %v: %s", i, err, lines[i])
+		}
+		if _, ok := entry["timestamp"]; !ok {
+			t.Errorf("Line %d has no timestamp: %s", i, lines[i])
+		}
+		for key, value := range want {
+			if entry[key] != value {
+				t.Errorf("Line %d: %s = %v, want %s", i, key, entry[key], value)
+			}
+		}
+	}
+}
+
+// TestLoggerJSONLevelFiltering tests that the configured level also applies to JSON output
+func TestLoggerJSONLevelFiltering(t *testing.T) {
+	buf := captureLog(t)
+
+	logger := NewLoggerWithFormat("warn", "JSON")
+	logger.Info("hidden message")
+	logger.Error("visible message")
+
+	if strings.Contains(buf.String(), "hidden message") {
+		t.Errorf("Expected info message to be filtered, got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"level":"error"`) {
+		t.Errorf("Expected error message to be logged, got: %s", buf.String())
+	}
+}
+
+// TestLevelLabel tests the labels used for each level, including fatal
+func TestLevelLabel(t *testing.T) {
+	tests := []struct {
+		level LogLevel
+		want  string
+	}{
+		{LogLevelDebug, "DEBUG"},
+		{LogLevelInfo, "INFO"},
+		{LogLevelWarn, "WARN"},
+		{LogLevelError, "ERROR"},
+	}
+	for _, tt := range tests {
+		if got := levelLabel(tt.level.slogLevel()); got != tt.want {
+			t.Errorf("levelLabel(%v) = %s, want %s", tt.level, got, tt.want)
+		}
+	}
+	if got := levelLabel(slogLevelFatal); got != "FATAL" {
+		t.Errorf("levelLabel(fatal) = %s, want FATAL", got)
+	}
+}
@@ -2,13 +2,15 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
+	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/slack-go/slack"
@@ -19,74 +21,69 @@ const (
 	SevenDaysTTL = 7 * 24 * 60 * 60
 	// NewRepoModalCallbackID is the callback ID for the new repo modal
 	NewRepoModalCallbackID = "create_github_repo_modal"
+	// CopilotIssueLabel is the label applied to the first issue so Copilot can pick it up
+	CopilotIssueLabel = "copilot"
+	// CopilotAssignee is the assignee used by the GitHub CLI to assign an issue to Copilot
+	CopilotAssignee = "@copilot"
+	// MaxIssueTitleLength is the maximum number of characters taken from the prompt for the issue title
+	MaxIssueTitleLength = 80
+	// MaxRepoNameLength is the maximum length of a GitHub repository name
+	MaxRepoNameLength = 100
+	// DotGithubRepo is the organization's special .github repository, the only name allowed to start with a dot
+	DotGithubRepo = ".github"
+	// ViewResponseTTL is how long a view submission response is kept for the relay to collect
+	ViewResponseTTL = 60 * time.Second
+	// DefaultVisibility is the visibility pre-selected in the new repo modal
+	DefaultVisibility = "public"
+	// DefaultGitignore is the gitignore template pre-selected in the new repo modal
+	DefaultGitignore = "Go"
+	// DefaultLicense is the license pre-selected in the new repo modal
+	DefaultLicense = "none"
+	// NoTemplate is the option value meaning no gitignore or license template
+	NoTemplate = "none"
 )
 
-// LogLevel represents the logging level
-type LogLevel int
-
-const (
-	LogLevelDebug LogLevel = iota
-	LogLevelInfo
-	LogLevelWarn
-	LogLevelError
-)
-
-// Logger provides structured logging with log levels
-type Logger struct {
-	level LogLevel
+// selectOption is a value and label offered in a static_select
+type selectOption struct {
+	Value string
+	Label string
 }
 
-// NewLogger creates a new Logger with the specified level
-func NewLogger(levelStr string) *Logger {
-	var level LogLevel
-	switch strings.ToLower(levelStr) {
-	case "debug":
-		level = LogLevelDebug
-	case "info":
-		level = LogLevelInfo
-	case "warn", "warning":
-		level = LogLevelWarn
-	case "error":
-		level = LogLevelError
-	default:
-		level = LogLevelInfo
-	}
-	return &Logger{level: level}
+// repoVisibilities lists the visibilities offered in the new repo modal (values match gh flags)
+var repoVisibilities = []selectOption{
+	{"public", "Public"},
+	{"private", "Private"},
+	{"internal", "Internal"},
 }
 
-// Debug logs a debug message
-func (l *Logger) Debug(format string, v ...interface{}) {
-	if l.level <= LogLevelDebug {
-		log.Printf("[DEBUG] "+format, v...)
-	}
-}
-
-// Info logs an info message
-func (l *Logger) Info(format string, v ...interface{}) {
-	if l.level <= LogLevelInfo {
-		log.Printf("[INFO] "+format, v...)
-	}
-}
-
-// Warn logs a warning message
-func (l *Logger) Warn(format string, v ...interface{}) {
-	if l.level <= LogLevelWarn {
-		log.Printf("[WARN] "+format, v...)
-	}
+// gitignoreTemplates lists the gitignore templates offered in the new repo modal
+var gitignoreTemplates = []selectOption{
+	{NoTemplate, "None"},
+	{"Go", "Go"},
+	{"Node", "Node"},
+	{"Python", "Python"},
+	{"Rust", "Rust"},
+	{"Java", "Java"},
+	{"Terraform", "Terraform"},
 }
 
-// Error logs an error message
-func (l *Logger) Error(format string, v ...interface{}) {
-	if l.level <= LogLevelError {
-		log.Printf("[ERROR] "+format, v...)
-	}
+// licenseTemplates lists the licenses offered in the new repo modal (values are GitHub license keys)
+var licenseTemplates = []selectOption{
+	{NoTemplate, "None"},
+	{"mit", "MIT"},
+	{"apache-2.0", "Apache 2.0"},
+	{"gpl-3.0", "GPL 3.0"},
+	{"bsd-3-clause", "BSD 3-Clause"},
+	{"mpl-2.0", "MPL 2.0"},
+	{"unlicense", "The Unlicense"},
 }
 
-// Fatal logs a fatal error message and exits
-// Fatal messages are always logged regardless of level as they indicate program termination
-func (l *Logger) Fatal(format string, v ...interface{}) {
-	log.Fatalf("[FATAL] "+format, v...)
-}
+var (
+	// invalidRepoNameChars matches runs of characters GitHub does not allow in repository names
+	invalidRepoNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	// repeatedHyphens matches the runs of hyphens normalizeRepoName collapses
+	repeatedHyphens = regexp.MustCompile(`-{2,}`)
+)
 
 // SlashCommandPayload represents the incoming slash command from Redis
 type SlashCommandPayload struct {
@@ -106,25 +103,53 @@ type SlashCommandPayload struct {
 
 // ViewSubmissionPayload represents the incoming view submission from Redis
 type ViewSubmissionPayload struct {
-	Type string `json:"type"`
+	Type     string `json:"type"`
+	Token    string `json:"token"`
+	APIAppID string `json:"api_app_id"`
+	Team     struct {
+		ID     string `json:"id"`
+		Domain string `json:"domain"`
+	} `json:"team"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		Name     string `json:"name"`
+		TeamID   string `json:"team_id"`
+	} `json:"user"`
 	View struct {
+		ID         string `json:"id"`
+		Hash       string `json:"hash"`
 		CallbackID string `json:"callback_id"`
 		State      struct {
 			Values map[string]map[string]struct {
-				Type  string `json:"type"`
-				Value string `json:"value"`
+				Type           string `json:"type"`
+				Value          string `json:"value"`
+				SelectedOption *struct {
+					Value string `json:"value"`
+				} `json:"selected_option"`
 			} `json:"values"`
 		} `json:"state"`
 	} `json:"view"`
 }
 
 // PoppitCommand represents the command message to be published to Poppit
+// CorrelationID is echoed back by Poppit on each command result
 type PoppitCommand struct {
-	Repo     string   `json:"repo"`
-	Branch   string   `json:"branch"`
-	Type     string   `json:"type"`
-	Dir      string   `json:"dir"`
-	Commands []string `json:"commands"`
+	Repo          string          `json:"repo"`
+	Branch        string          `json:"branch"`
+	Type          string          `json:"type"`
+	Dir           string          `json:"dir"`
+	Commands      []string        `json:"commands"`
+	CorrelationID string          `json:"correlation_id"`
+	Metadata      *PoppitMetadata `json:"metadata,omitempty"`
+}
+
+// PoppitMetadata records who a Poppit command was requested by
+type PoppitMetadata struct {
+	UserID     string `json:"user_id"`
+	UserName   string `json:"user_name,omitempty"`
+	TeamID     string `json:"team_id,omitempty"`
+	TeamDomain string `json:"team_domain,omitempty"`
 }
 
 // SlackLinerMessage represents the message to be sent to SlackLiner
@@ -134,67 +159,44 @@ type SlackLinerMessage struct {
 	TTL     int    `json:"ttl,omitempty"`
 }
 
-// Config holds the application configuration
-type Config struct {
-	RedisAddr                  string
-	RedisPassword              string
-	RedisChannel               string
-	RedisViewSubmissionChannel string
-	RedisPoppitList            string
-	RedisSlackLinerList        string
-	SlackToken                 string
-	SlackChannelNewRepo        string
-	GithubOrg                  string
-	WorkingDir                 string
-	LogLevel                   string
-}
-
-func loadConfig() (*Config, error) {
-	config := &Config{
-		RedisAddr:                  getEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword:              getEnv("REDIS_PASSWORD", ""),
-		RedisChannel:               getEnv("REDIS_CHANNEL", "slack-commands"),
-		RedisViewSubmissionChannel: getEnv("REDIS_VIEW_SUBMISSION_CHANNEL", "slack-relay-view-submission"),
-		RedisPoppitList:            getEnv("REDIS_POPPIT_LIST", "poppit:notifications"),
-		RedisSlackLinerList:        getEnv("REDIS_SLACKLINER_LIST", "slack_messages"),
-		SlackToken:                 getEnv("SLACK_BOT_TOKEN", ""),
-		SlackChannelNewRepo:        getEnv("SLACK_CHANNEL_NEW_REPO", "#new-repo"),
-		GithubOrg:                  getEnv("GITHUB_ORG", ""),
-		WorkingDir:                 getEnv("WORKING_DIR", "/tmp"),
-		LogLevel:                   getEnv("LOG_LEVEL", "info"),
-	}
+func main() {
+	flags := flag.NewFlagSet("slashviberepo", flag.ExitOnError)
+	configFile := flags.String("config", "", "path to a YAML config file (overrides CONFIG_FILE)")
+	showConfig := flags.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flags.Parse(os.Args[1:])
 
-	if config.SlackToken == "" {
-		return nil, fmt.Errorf("SLACK_BOT_TOKEN must be set via environment variable")
+	// Subcommands load the config through CONFIG_FILE too
+	if *configFile != "" {
+		os.Setenv("CONFIG_FILE", *configFile)
 	}
 
-	if config.GithubOrg == "" {
-		return nil, fmt.Errorf("GITHUB_ORG must be set via environment variable")
+	if *showConfig {
+		if err := printConfig(os.Stdout, getEnv("CONFIG_FILE", ""), os.LookupEnv); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
-	return config, nil
-}
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	// Admin subcommands run instead of the service
+	if code, ok := runAdminCommand(flags.Args()); ok {
+		os.Exit(code)
 	}
-	return defaultValue
-}
 
-func main() {
 	// Create initial logger for startup (before config is loaded)
 	logger := NewLogger("info")
 	logger.Info("Starting SlashVibeRepo service...")
 
 	config, err := loadConfig()
 	if err != nil {
-		logger.Fatal("Failed to load configuration: %v", err)
+		logger.Fatal("Failed to load configuration", "error", err)
 	}
 
-	// Update logger with configured log level
-	logger = NewLogger(config.LogLevel)
-	logger.Info("Log level set to: %s", config.LogLevel)
+	configs := NewConfigStore(config)
+
+	// Update logger with configured log level and format
+	logger = NewLoggerWithFormat(config.LogLevel, config.LogFormat)
+	logger.Info("Logger configured", "log_level", config.LogLevel, "log_format", config.LogFormat)
 
 	// Initialize Slack client
 	slackClient := slack.New(config.SlackToken)
@@ -209,9 +211,9 @@ func main() {
 	// Test Redis connection
 	ctx := context.Background()
 	if err := redisClient.Ping(ctx).Err(); err != nil {
-		logger.Fatal("Failed to connect to Redis: %v", err)
+		logger.Fatal("Failed to connect to Redis", "redis_addr", config.RedisAddr, "error", err)
 	}
-	logger.Info("Connected to Redis at %s", config.RedisAddr)
+	logger.Info("Connected to Redis", "redis_addr", config.RedisAddr)
 
 	// Create a context that can be cancelled
 	ctx, cancel := context.WithCancel(ctx)
@@ -227,98 +229,97 @@ func main() {
 		cancel()
 	}()
 
-	// Subscribe to Redis channels
-	logger.Info("Subscribing to Redis channel: %s", config.RedisChannel)
-	pubsub := redisClient.Subscribe(ctx, config.RedisChannel)
-	defer pubsub.Close()
-
-	logger.Info("Subscribing to Redis view submission channel: %s", config.RedisViewSubmissionChannel)
-	viewSubmissionPubsub := redisClient.Subscribe(ctx, config.RedisViewSubmissionChannel)
-	defer viewSubmissionPubsub.Close()
+	// Serve /metrics, /healthz and /readyz if an HTTP address is configured
+	health := NewHealthChecker(
+		func(ctx context.Context) error { return redisClient.Ping(ctx).Err() },
+		func(ctx context.Context) error {
+			_, err := slackClient.AuthTestContext(ctx)
+			return err
+		},
+	)
+	if config.HTTPAddr != "" {
+		go health.Run(ctx, logger)
+		startHTTPServer(ctx, logger, config.HTTPAddr, newHTTPHandler(health))
+	}
 
-	// Wait for subscription confirmation
-	_, err = pubsub.Receive(ctx)
+	repoChecker := NewGitHubRepoChecker(config.GithubAPIURL, config.GithubToken)
+	authorizer, err := NewPolicyAuthorizer(logger, config.AuthPolicyFile, slackClient)
 	if err != nil {
-		logger.Fatal("Failed to subscribe to Redis channel: %v", err)
+		logger.Fatal("Failed to load authorization policy", "path", config.AuthPolicyFile, "error", err)
 	}
-	logger.Info("Successfully subscribed to Redis channel")
-
-	_, err = viewSubmissionPubsub.Receive(ctx)
+	if config.AuthPolicyFile == "" {
+		logger.Warn("No authorization policy configured, any user may create repositories")
+	}
+	catalog, err := NewTemplateCatalog(logger, config.TemplatesFile)
 	if err != nil {
-		logger.Fatal("Failed to subscribe to view submission channel: %v", err)
-	}
-	logger.Info("Successfully subscribed to view submission channel")
-
-	// Process messages from both channels
-	ch := pubsub.Channel()
-	viewSubmissionCh := viewSubmissionPubsub.Channel()
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Info("Shutting down...")
-			return
-		case msg := <-ch:
-			if msg == nil {
-				continue
-			}
-			handleMessage(ctx, logger, slackClient, msg.Payload)
-		case msg := <-viewSubmissionCh:
-			if msg == nil {
-				continue
-			}
-			handleViewSubmission(ctx, logger, redisClient, config, msg.Payload)
-		}
+		logger.Fatal("Failed to load templates", "path", config.TemplatesFile, "error", err)
+	}
+
+	service := &Service{
+		Logger:      logger,
+		Configs:     configs,
+		Redis:       redisClient,
+		Views:       slackClient,
+		Poster:      slackClient,
+		Queue:       &RedisQueue{client: redisClient, configs: configs},
+		Notifier:    &SlackLinerNotifier{client: redisClient, configs: configs},
+		RepoChecker: repoChecker,
+		Authorizer:  authorizer,
+		Catalog:     catalog,
+		Health:      health,
+	}
+	if err := service.Run(ctx); err != nil {
+		logger.Fatal("Service stopped", "error", err)
 	}
 }
 
-func handleMessage(ctx context.Context, logger *Logger, slackClient *slack.Client, payload string) {
-	logger.Debug("Received message: %s", payload)
+// handleNewRepoCommand opens the new repo modal for a permitted user
+func (s *Service) handleNewRepoCommand(ctx context.Context, cmd *SlashCommandPayload) {
+	config := s.Configs.Get()
+	s.Logger.Debug("Handling /new-repo command", "trigger_id", cmd.TriggerID, "user_id", cmd.UserID)
 
-	var cmd SlashCommandPayload
-	if err := json.Unmarshal([]byte(payload), &cmd); err != nil {
-		logger.Error("Failed to unmarshal payload: %v", err)
+	orgs := creatorOrgs(ctx, s.Logger, s.Authorizer, config, cmd.UserID, AuthStageCommand)
+	if len(orgs) == 0 {
+		respondEphemeral(ctx, s.Logger, cmd.ResponseURL, notAuthorizedMessage(config.Orgs()...))
 		return
 	}
 
-	logger.Info("Processing command: %s from user: %s", cmd.Command, cmd.UserName)
-
-	switch cmd.Command {
-	case "/new-repo":
-		handleNewRepoCommand(ctx, logger, slackClient, &cmd)
-	default:
-		logger.Warn("Unknown command: %s", cmd.Command)
+	// Only offer a choice of organization when more than one is configured
+	if len(config.Orgs()) == 1 {
+		orgs = nil
 	}
-}
-
-func handleNewRepoCommand(ctx context.Context, logger *Logger, slackClient *slack.Client, cmd *SlashCommandPayload) {
-	logger.Debug("Handling /new-repo command with trigger_id: %s", cmd.TriggerID)
-
-	modalView := createNewRepoModal(cmd.Text)
+	modalView := createNewRepoModal(cmd.Text, orgs, config.DefaultOrg(cmd.ChannelID), s.Catalog.Templates())
 
-	_, err := slackClient.OpenViewContext(ctx, cmd.TriggerID, modalView)
+	start := time.Now()
+	_, err := s.Views.OpenViewContext(ctx, cmd.TriggerID, modalView)
+	openViewDurationSeconds.Observe(time.Since(start).Seconds())
 	if err != nil {
-		logger.Error("Failed to open modal: %v", err)
+		slackAPIErrorsTotal.WithLabelValues(SlackMethodViewsOpen).Inc()
+		s.Logger.Error("Failed to open modal", "user_id", cmd.UserID, "error", err)
 		return
 	}
 
-	logger.Info("Successfully opened new-repo modal for user: %s", cmd.UserName)
+	s.Logger.Info("Successfully opened new-repo modal", "user_id", cmd.UserID, "user_name", cmd.UserName)
 }
 
-func createNewRepoModal(repoName string) slack.ModalViewRequest {
+// createNewRepoModal builds the new repo modal
+// If orgs is not empty an organization select is added, pre-selecting defaultOrg if it is one of them,
+// and if templates is not empty a template select is added
+func createNewRepoModal(repoName string, orgs []string, defaultOrg string, templates []RepoTemplate) slack.ModalViewRequest {
 	// Create the repository name input block
 	repoNameInput := slack.NewPlainTextInputBlockElement(
 		slack.NewTextBlockObject(slack.PlainTextType, "my-awesome-repo", false, false),
 		"repo_name_input",
 	)
-	// Pre-populate the repository name if provided in the command text
-	if repoName != "" {
-		repoNameInput = repoNameInput.WithInitialValue(repoName)
+	// Pre-populate the repository name from the command text, e.g. "My Cool Repo" becomes "My-Cool-Repo"
+	if suggestion := normalizeRepoName(repoName); suggestion != "" {
+		repoNameInput = repoNameInput.WithInitialValue(suggestion)
 	}
 
 	repoNameBlock := slack.NewInputBlock(
 		"repo-name",
 		slack.NewTextBlockObject(slack.PlainTextType, "Repository Name", false, false),
-		slack.NewTextBlockObject(slack.PlainTextType, "Letters, numbers, hyphens only (no spaces)", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "Letters, numbers, hyphens, underscores and dots (no spaces)", false, false),
 		repoNameInput,
 	)
 
@@ -336,6 +337,11 @@ func createNewRepoModal(repoName string) slack.ModalViewRequest {
 	)
 	repoDescBlock.Optional = true
 
+	// Create the visibility, gitignore and license select blocks
+	visibilityBlock := newStaticSelectBlock("repo-visibility", "repo_visibility_select", "Visibility", repoVisibilities, DefaultVisibility)
+	gitignoreBlock := newStaticSelectBlock("repo-gitignore", "repo_gitignore_select", ".gitignore Template", gitignoreTemplates, DefaultGitignore)
+	licenseBlock := newStaticSelectBlock("repo-license", "repo_license_select", "License", licenseTemplates, DefaultLicense)
+
 	// Create the AI prompt input block
 	aiPromptInput := slack.NewPlainTextInputBlockElement(
 		slack.NewTextBlockObject(slack.PlainTextType, "A simple Go service", false, false),
@@ -350,6 +356,28 @@ func createNewRepoModal(repoName string) slack.ModalViewRequest {
 	)
 	aiPromptBlock.Optional = true
 
+	var blocks []slack.Block
+
+	// The organization comes first, as it scopes the name
+	if len(orgs) > 0 {
+		initialOrg := orgs[0]
+		for _, org := range orgs {
+			if org == defaultOrg {
+				initialOrg = org
+			}
+		}
+		blocks = append(blocks, newStaticSelectBlock("repo-org", "repo_org_select", "Organization", orgOptions(orgs), initialOrg))
+	}
+	blocks = append(blocks, repoNameBlock, repoDescBlock, visibilityBlock)
+
+	// The template select goes before the .gitignore and license selects it overrides
+	if len(templates) > 0 {
+		templateBlock := newStaticSelectBlock("repo-template", "repo_template_select", "Template", templateOptions(templates), BlankTemplate)
+		templateBlock.Hint = slack.NewTextBlockObject(slack.PlainTextType, "A template replaces the .gitignore and license choices", false, false)
+		blocks = append(blocks, templateBlock)
+	}
+	blocks = append(blocks, gitignoreBlock, licenseBlock, aiPromptBlock)
+
 	// Create the modal view
 	modalView := slack.ModalViewRequest{
 		Type:       slack.VTModal,
@@ -367,137 +395,409 @@ func createNewRepoModal(repoName string) slack.ModalViewRequest {
 			Text: "Submit",
 		},
 		Blocks: slack.Blocks{
-			BlockSet: []slack.Block{
-				repoNameBlock,
-				repoDescBlock,
-				aiPromptBlock,
-			},
+			BlockSet: blocks,
 		},
 	}
 
 	return modalView
 }
 
-// handleViewSubmission processes view submission payloads from Redis
-func handleViewSubmission(ctx context.Context, logger *Logger, redisClient *redis.Client, config *Config, payload string) {
-	logger.Debug("Received view submission: %s", payload)
-
-	var submission ViewSubmissionPayload
-	if err := json.Unmarshal([]byte(payload), &submission); err != nil {
-		logger.Error("Failed to unmarshal view submission payload: %v", err)
-		return
+// newStaticSelectBlock creates an input block with a static_select of options
+func newStaticSelectBlock(blockID, actionID, label string, options []selectOption, initialValue string) *slack.InputBlock {
+	optionObjects := make([]*slack.OptionBlockObject, 0, len(options))
+	var initialOption *slack.OptionBlockObject
+	for _, option := range options {
+		optionObject := slack.NewOptionBlockObject(
+			option.Value,
+			slack.NewTextBlockObject(slack.PlainTextType, option.Label, false, false),
+			nil,
+		)
+		optionObjects = append(optionObjects, optionObject)
+		if option.Value == initialValue {
+			initialOption = optionObject
+		}
 	}
 
-	// Only handle our specific callback_id
-	if submission.View.CallbackID != NewRepoModalCallbackID {
-		logger.Debug("Ignoring view submission with callback_id: %s", submission.View.CallbackID)
-		return
+	selectElement := slack.NewOptionsSelectBlockElement(
+		slack.OptTypeStatic,
+		slack.NewTextBlockObject(slack.PlainTextType, label, false, false),
+		actionID,
+		optionObjects...,
+	)
+	if initialOption != nil {
+		selectElement = selectElement.WithInitialOption(initialOption)
 	}
 
+	return slack.NewInputBlock(
+		blockID,
+		slack.NewTextBlockObject(slack.PlainTextType, label, false, false),
+		nil,
+		selectElement,
+	)
+}
+
+// handleViewSubmission processes submissions of the new repo modal
+// A non-nil response is sent back to Slack, e.g. to show validation errors in the modal
+// An error is only returned when the Poppit command could not be queued
+func (s *Service) handleViewSubmission(ctx context.Context, submission *ViewSubmissionPayload) (*slack.ViewSubmissionResponse, error) {
+	config := s.Configs.Get()
+
 	// Extract values from the view state
-	values := extractViewValues(submission)
-	logger.Debug("Extracted values: %+v", values)
+	values := extractViewValues(*submission)
+	s.Logger.Debug("Extracted values", "values", values)
 
-	// Get repository name and description
-	repoName, ok := values["repo-name"]
-	if !ok || repoName == "" {
-		logger.Error("Missing repository name in view submission")
-		return
+	// Without an organization select, the modal was opened with a single organization configured
+	org, orgErr := selectedOption(values, "repo-org", orgOptions(config.Orgs()), config.GithubOrg)
+	if orgErr != nil {
+		s.Logger.Warn("Invalid organization in view submission", "error", orgErr.Message)
+		validationFailuresTotal.WithLabelValues(ValidationInvalidOption).Inc()
+		return slack.NewErrorsViewSubmissionResponse(collectBlockErrors(orgErr)), nil
+	}
+
+	// Checked again in case the policy changed while the modal was open
+	if !authorizeUser(ctx, s.Logger, s.Authorizer, submission.User.ID, org, RoleCreator, AuthStageViewSubmission) {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{"repo-name": notAuthorizedMessage(org)}), nil
 	}
 
+	// Get repository name and description
+	repoName := values["repo-name"]
+
 	// Validate repository name (GitHub allows alphanumeric, hyphens, underscores, dots)
-	if !isValidRepoName(repoName) {
-		logger.Error("Invalid repository name: %s", repoName)
-		return
+	if problem := validateRepoName(repoName); problem != "" {
+		s.Logger.Warn("Invalid repository name", "repo_name", repoName, "problem", problem)
+		validationFailuresTotal.WithLabelValues(ValidationInvalidName).Inc()
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{"repo-name": problem}), nil
 	}
 
 	repoDesc := values["repo-description"]
+	aiPrompt := strings.TrimSpace(values["ai-prompt"])
+
+	// Selected options are checked against the offered options as they end up in a shell command
+	visibility, visibilityErr := selectedOption(values, "repo-visibility", repoVisibilities, DefaultVisibility)
+	gitignore, gitignoreErr := selectedOption(values, "repo-gitignore", gitignoreTemplates, DefaultGitignore)
+	license, licenseErr := selectedOption(values, "repo-license", licenseTemplates, DefaultLicense)
+	templates := s.Catalog.Templates()
+	templateName, templateErr := selectedOption(values, "repo-template", templateOptions(templates), BlankTemplate)
+	if errs := collectBlockErrors(visibilityErr, gitignoreErr, licenseErr, templateErr); len(errs) > 0 {
+		s.Logger.Warn("Invalid options in view submission", "errors", errs)
+		validationFailuresTotal.WithLabelValues(ValidationInvalidOption).Inc()
+		return slack.NewErrorsViewSubmissionResponse(errs), nil
+	}
 
 	// Build the repository full name
-	repoFullName := fmt.Sprintf("%s/%s", config.GithubOrg, repoName)
+	repoFullName := fmt.Sprintf("%s/%s", org, repoName)
+
+	// Slack retries and relay replays deliver the same submission again; only queue it once
+	// Claimed before the existence check, so a replay of a request that has since been created is still a duplicate
+	claimed, err := claimSubmission(ctx, s.Redis, submission, repoFullName)
+	if err != nil {
+		return nil, &HandlerError{Stage: StageDeduplicate, Err: fmt.Errorf("failed to record view submission: %w", err), Retryable: true}
+	}
+	if !claimed {
+		s.Logger.Info("Ignoring duplicate view submission", "view_id", submission.View.ID, "repo", repoFullName)
+		return nil, nil
+	}
+
+	// Catch name collisions now rather than as a Poppit failure; if GitHub cannot be reached, let Poppit find out
+	exists, err := s.RepoChecker.RepoExists(ctx, repoFullName)
+	if err != nil {
+		s.Logger.Warn("Could not check whether repository exists, continuing", "repo", repoFullName, "error", err)
+	} else if exists {
+		s.Logger.Info("Repository already exists", "repo", repoFullName)
+		validationFailuresTotal.WithLabelValues(ValidationRepoExists).Inc()
+		// The submission was not queued, so a resubmission after renaming elsewhere must not look like a duplicate
+		if releaseErr := releaseSubmission(ctx, s.Redis, submission, repoFullName); releaseErr != nil {
+			s.Logger.Error("Failed to release view submission", "view_id", submission.View.ID, "repo", repoFullName, "error", releaseErr)
+		}
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			"repo-name": fmt.Sprintf("%s already exists. Please choose another name.", repoFullName),
+		}), nil
+	}
 
 	// Build the gh repo create command
-	ghRepoCreateCmd := fmt.Sprintf("gh repo create %s --public --add-readme --gitignore Go", repoFullName)
-	if repoDesc != "" {
-		// Use single quotes for better safety, but escape any single quotes in the description
-		escapedDesc := strings.ReplaceAll(repoDesc, `'`, `'\''`)
-		ghRepoCreateCmd = fmt.Sprintf("%s --description '%s'", ghRepoCreateCmd, escapedDesc)
+	template := findTemplate(templates, templateName)
+	var ghRepoCreateCmd *ShellCommand
+	if template != nil {
+		ghRepoCreateCmd = buildTemplateRepoCreateCommand(repoFullName, repoDesc, visibility, template)
+	} else {
+		ghRepoCreateCmd = buildRepoCreateCommand(repoFullName, repoDesc, visibility, gitignore, license)
+	}
+
+	commands := renderCommands(ghRepoCreateCmd)
+
+	// Open the Copilot issue straight after creation, before any template commands
+	if aiPrompt != "" {
+		commands = append(commands, renderCommands(buildCopilotIssueCommands(repoFullName, aiPrompt)...)...)
+	}
+
+	// Make the requester an admin if we know their GitHub login
+	if login, ok := config.GithubUsers[submission.User.ID]; ok {
+		commands = append(commands, buildAddCollaboratorCommand(repoFullName, login).String())
 	}
 
-	ghRepoCloneCmd := fmt.Sprintf("gh repo clone %s", repoFullName)
+	// A template brings its own follow-up commands, which are shell strings already
+	if template != nil {
+		commands = append(commands, templateCommands(template, repoFullName)...)
+	} else {
+		ghRepoCloneCmd := NewShellCommand("gh", "repo", "clone", repoFullName)
+
+		ghVibeInitCmd := NewShellCommand("gh", "vibe", "init", repoFullName)
 
-	ghVibeInitCmd := fmt.Sprintf("gh vibe init %s", repoFullName)
+		commands = append(commands, renderCommands(ghRepoCloneCmd, ghVibeInitCmd)...)
+	}
 
 	// Create Poppit command message
 	poppitCmd := PoppitCommand{
-		Repo:   repoFullName,
-		Branch: "refs/heads/main",
-		Type:   "slash-vibe-new-repo",
-		Dir:    config.WorkingDir,
-		Commands: []string{
-			ghRepoCreateCmd,
-			ghRepoCloneCmd,
-			ghVibeInitCmd,
-		},
+		Repo:          repoFullName,
+		Branch:        "refs/heads/main",
+		Type:          PoppitNewRepoType,
+		Dir:           config.WorkingDir,
+		Commands:      commands,
+		CorrelationID: newCorrelationID(),
+		Metadata:      submissionMetadata(submission),
+	}
+
+	request := &RepoRequest{
+		Repo:        repoFullName,
+		Description: repoDesc,
+		Visibility:  visibility,
+		HasIssue:    aiPrompt != "",
+		RequestedBy: submission.User.ID,
+		RequestedAt: time.Now().UTC(),
+		Command:     poppitCmd,
+	}
+	held := needsApproval(ctx, s.Logger, s.Authorizer, config, request)
+
+	// Count the request against the rate limits before it is queued; a request held for
+	// approval is only checked now and counted once approved, so a rejected or expired one never counts
+	var exceeded *RateLimitExceeded
+	if held {
+		exceeded, err = checkRateLimit(ctx, s.Redis, config, org, submission.User.ID)
+	} else {
+		exceeded, err = reserveRateLimit(ctx, s.Redis, config, org, submission.User.ID, poppitCmd.CorrelationID)
+	}
+	if err != nil || exceeded != nil {
+		// The submission was not queued, so a retry or resubmission must not look like a duplicate
+		if releaseErr := releaseSubmission(ctx, s.Redis, submission, repoFullName); releaseErr != nil {
+			s.Logger.Error("Failed to release view submission", "view_id", submission.View.ID, "repo", repoFullName, "error", releaseErr)
+		}
 	}
-
-	// Push to Poppit list
-	poppitPayload, err := json.Marshal(poppitCmd)
 	if err != nil {
-		logger.Error("Failed to marshal Poppit command: %v", err)
-		return
+		return nil, &HandlerError{Stage: StageRateLimit, Err: err, Retryable: true}
+	}
+	if exceeded != nil {
+		rateLimitRejectionsTotal.WithLabelValues(exceeded.Scope).Inc()
+		s.Logger.Warn("Rate limit exceeded", "user_id", submission.User.ID, "repo", repoFullName, "scope", exceeded.Scope, "limit", exceeded.Limit.String(), "retry_after", exceeded.RetryAfter.String())
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{"repo-name": rateLimitMessage(exceeded, "")}), nil
 	}
 
-	err = redisClient.RPush(ctx, config.RedisPoppitList, string(poppitPayload)).Err()
+	// Hold the request for an approver instead of queueing it
+	if held {
+		err = s.requestApproval(ctx, config, request)
+	} else {
+		err = s.queueRepoRequest(ctx, config, request)
+	}
 	if err != nil {
-		logger.Error("Failed to push to Poppit list: %v", err)
-		return
+		// Release the claim so a retry of this submission is not mistaken for a duplicate
+		if releaseErr := releaseSubmission(ctx, s.Redis, submission, repoFullName); releaseErr != nil {
+			s.Logger.Error("Failed to release view submission", "view_id", submission.View.ID, "repo", repoFullName, "error", releaseErr)
+		}
+		// Nor should it count against the rate limits twice
+		if releaseErr := releaseRateLimit(ctx, s.Redis, config, org, submission.User.ID, poppitCmd.CorrelationID); releaseErr != nil {
+			s.Logger.Error("Failed to release rate limit", "user_id", submission.User.ID, "repo", repoFullName, "error", releaseErr)
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// queueRepoRequest pushes the request's Poppit command and sends the confirmation message
+func (s *Service) queueRepoRequest(ctx context.Context, config *Config, request *RepoRequest) error {
+	poppitCmd := request.Command
+
+	// Record the request before queueing it so fast results can still be matched
+	pending := &PendingRequest{
+		Repo:        request.Repo,
+		Commands:    poppitCmd.Commands,
+		Channel:     config.SlackChannelNewRepo,
+		RequestedAt: time.Now().UTC(),
+	}
+	if err := storePendingRequest(ctx, s.Redis, poppitCmd.CorrelationID, pending); err != nil {
+		s.Logger.Warn("Failed to store pending request, the outcome will not be reported", "repo", request.Repo, "correlation_id", poppitCmd.CorrelationID, "error", err)
+	}
+
+	if err := s.Queue.Enqueue(ctx, &poppitCmd); err != nil {
+		return &HandlerError{Stage: StageQueuePoppit, Err: err, Retryable: true}
 	}
 
-	logger.Info("Successfully pushed Poppit command for repo: %s", repoFullName)
-	logger.Debug("Poppit command payload: %s", string(poppitPayload))
+	s.Logger.Info("Successfully pushed Poppit command", "repo", request.Repo, "correlation_id", poppitCmd.CorrelationID)
+	s.Logger.Debug("Poppit commands", "commands", poppitCmd.Commands)
 
 	// Send confirmation message to SlackLiner
-	sendNewRepoConfirmation(ctx, logger, redisClient, config, repoFullName, repoDesc)
+	s.sendNewRepoConfirmation(ctx, config, request)
+	return nil
+}
+
+// submissionMetadata returns the Poppit metadata for the user who submitted the modal
+func submissionMetadata(submission *ViewSubmissionPayload) *PoppitMetadata {
+	// Slack sets user.team_id to the user's home workspace, which differs from team.id for shared channels
+	teamID := submission.User.TeamID
+	if teamID == "" {
+		teamID = submission.Team.ID
+	}
+	userName := submission.User.Username
+	if userName == "" {
+		userName = submission.User.Name
+	}
+	return &PoppitMetadata{
+		UserID:     submission.User.ID,
+		UserName:   userName,
+		TeamID:     teamID,
+		TeamDomain: submission.Team.Domain,
+	}
+}
+
+// formatTTL formats a duration without trailing zero units, e.g. "24h" rather than "24h0m0s"
+func formatTTL(ttl time.Duration) string {
+	text := ttl.String()
+	if ttl%time.Minute == 0 {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if ttl%time.Hour == 0 {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// orgOptions turns organization names into select options
+func orgOptions(orgs []string) []selectOption {
+	options := make([]selectOption, 0, len(orgs))
+	for _, org := range orgs {
+		options = append(options, selectOption{Value: org, Label: org})
+	}
+	return options
+}
+
+// blockError is a validation error for a single block in the modal
+type blockError struct {
+	BlockID string
+	Message string
+}
+
+// collectBlockErrors turns the non-nil block errors into a block ID to message map
+func collectBlockErrors(errs ...*blockError) map[string]string {
+	result := make(map[string]string)
+	for _, err := range errs {
+		if err != nil {
+			result[err.BlockID] = err.Message
+		}
+	}
+	return result
+}
+
+// selectedOption returns the value selected for blockID, or defaultValue if the block is absent
+// Values that are not one of the offered options are rejected
+func selectedOption(values map[string]string, blockID string, options []selectOption, defaultValue string) (string, *blockError) {
+	value, ok := values[blockID]
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+	for _, option := range options {
+		if option.Value == value {
+			return value, nil
+		}
+	}
+	return "", &blockError{BlockID: blockID, Message: fmt.Sprintf("%q is not a supported option.", value)}
+}
+
+// buildRepoCreateCommand builds the gh repo create command for the selected options
+func buildRepoCreateCommand(repoFullName, repoDesc, visibility, gitignore, license string) *ShellCommand {
+	ghRepoCreateCmd := NewShellCommand("gh", "repo", "create", repoFullName, "--"+visibility, "--add-readme")
+	if gitignore != NoTemplate {
+		ghRepoCreateCmd.Flag("--gitignore", gitignore)
+	}
+	if license != NoTemplate {
+		ghRepoCreateCmd.Flag("--license", license)
+	}
+	if repoDesc != "" {
+		ghRepoCreateCmd.Flag("--description", repoDesc)
+	}
+	return ghRepoCreateCmd
+}
+
+// buildCopilotIssueCommands builds the commands that open the first issue for Copilot
+func buildCopilotIssueCommands(repoFullName, prompt string) []*ShellCommand {
+	// The label must exist before it can be applied; --force makes this idempotent
+	ghLabelCreateCmd := NewShellCommand("gh", "label", "create", CopilotIssueLabel).
+		Flag("--repo", repoFullName).
+		Flag("--description", "Work for GitHub Copilot").
+		Arg("--force")
+
+	ghIssueCreateCmd := NewShellCommand("gh", "issue", "create").
+		Flag("--repo", repoFullName).
+		Flag("--title", copilotIssueTitle(prompt)).
+		Flag("--body", prompt).
+		Flag("--label", CopilotIssueLabel).
+		Flag("--assignee", CopilotAssignee)
+
+	return []*ShellCommand{ghLabelCreateCmd, ghIssueCreateCmd}
+}
+
+// buildAddCollaboratorCommand builds the command that makes login an admin of the new repository
+func buildAddCollaboratorCommand(repoFullName, login string) *ShellCommand {
+	return NewShellCommand("gh", "api").
+		Flag("--method", "PUT").
+		Arg(fmt.Sprintf("repos/%s/collaborators/%s", repoFullName, login)).
+		Flag("-f", "permission=admin")
+}
+
+// copilotIssueTitle derives an issue title from the first non-empty line of the prompt
+func copilotIssueTitle(prompt string) string {
+	title := ""
+	for _, line := range strings.Split(prompt, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			title = line
+			break
+		}
+	}
+
+	runes := []rune(title)
+	if len(runes) > MaxIssueTitleLength {
+		title = strings.TrimSpace(string(runes[:MaxIssueTitleLength-1])) + "…"
+	}
+
+	return title
 }
 
 // sendNewRepoConfirmation sends a confirmation message to SlackLiner
-func sendNewRepoConfirmation(ctx context.Context, logger *Logger, redisClient *redis.Client, config *Config, repoFullName, repoDesc string) {
+func (s *Service) sendNewRepoConfirmation(ctx context.Context, config *Config, request *RepoRequest) {
+	repoFullName := request.Repo
+
 	// Build the GitHub repository URL
 	repoURL := fmt.Sprintf("https://github.com/%s", repoFullName)
 
 	// Build the confirmation message
 	confirmationText := fmt.Sprintf("✅ New repository creation initiated!\n\n*Repository:* <%s|%s>", repoURL, repoFullName)
-	if repoDesc != "" {
-		confirmationText = fmt.Sprintf("%s\n*Description:* %s", confirmationText, repoDesc)
+	if request.Description != "" {
+		confirmationText = fmt.Sprintf("%s\n*Description:* %s", confirmationText, request.Description)
 	}
-
-	// Create the SlackLiner message with 7 days TTL
-	slackMessage := SlackLinerMessage{
-		Channel: config.SlackChannelNewRepo,
-		Text:    confirmationText,
-		TTL:     SevenDaysTTL,
+	if request.RequestedBy != "" {
+		confirmationText = fmt.Sprintf("%s\n*Requested by:* <@%s>", confirmationText, request.RequestedBy)
 	}
-
-	// Marshal to JSON
-	messagePayload, err := json.Marshal(slackMessage)
-	if err != nil {
-		logger.Error("Failed to marshal SlackLiner message: %v", err)
-		return
+	if request.HasIssue {
+		// The issue does not exist yet and its number is not known (a template may open issues
+		// of its own), so link to the issues carrying the Copilot label
+		issuesURL := fmt.Sprintf("%s/issues?q=%s", repoURL, url.QueryEscape("label:"+CopilotIssueLabel))
+		confirmationText = fmt.Sprintf("%s\n*Copilot Issue:* <%s|%s issues>", confirmationText, issuesURL, CopilotIssueLabel)
 	}
 
-	// Push to SlackLiner Redis list
-	err = redisClient.RPush(ctx, config.RedisSlackLinerList, string(messagePayload)).Err()
-	if err != nil {
-		logger.Error("Failed to push to SlackLiner list: %v", err)
-		return
+	if s.notify(ctx, config.SlackChannelNewRepo, confirmationText) {
+		s.Logger.Info("Successfully sent confirmation message", "repo", repoFullName)
 	}
-
-	logger.Info("Successfully sent confirmation message to SlackLiner for repo: %s", repoFullName)
 }
 
 // extractViewValues extracts values from the view submission state
-// Equivalent to: jq '.view.state.values | map_values(.[] | .value)'
+// Equivalent to: jq '.view.state.values | map_values(.[] | .value // .selected_option.value)'
 func extractViewValues(submission ViewSubmissionPayload) map[string]string {
 	result := make(map[string]string)
 
@@ -506,7 +806,12 @@ func extractViewValues(submission ViewSubmissionPayload) map[string]string {
 		// In practice, each block contains exactly one action_id
 		// We extract the first (and only) value from each block
 		for _, valueObj := range blockValues {
-			result[blockID] = valueObj.Value
+			// Text inputs carry a plain value, selects carry the selected option
+			if valueObj.SelectedOption != nil {
+				result[blockID] = valueObj.SelectedOption.Value
+			} else {
+				result[blockID] = valueObj.Value
+			}
 			break
 		}
 	}
@@ -517,13 +822,74 @@ func extractViewValues(submission ViewSubmissionPayload) map[string]string {
 // isValidRepoName validates that the repository name contains only valid characters
 // GitHub allows alphanumeric characters, hyphens, underscores, and dots
 func isValidRepoName(name string) bool {
-	if name == "" || len(name) > 100 {
-		return false
+	return validateRepoName(name) == ""
+}
+
+// validateRepoName checks a repository name against GitHub's naming rules
+// It returns a message suitable for showing to the user, or "" if the name is valid;
+// the message suggests a valid name when one can be derived from name
+func validateRepoName(name string) string {
+	message := repoNameProblem(name)
+	// A truncated name is rarely what the user wants, so over-long names get no suggestion
+	if message == "" || name == "" || len(name) > MaxRepoNameLength {
+		return message
+	}
+	if suggestion := normalizeRepoName(name); suggestion != "" && suggestion != name {
+		message = fmt.Sprintf("%s Try %q.", message, suggestion)
+	}
+	return message
+}
+
+// repoNameProblem returns what is wrong with name, or "" if GitHub accepts it as-is
+func repoNameProblem(name string) string {
+	if name == "" {
+		return "Please enter a repository name."
+	}
+	if len(name) > MaxRepoNameLength {
+		return fmt.Sprintf("Repository names must be %d characters or fewer.", MaxRepoNameLength)
 	}
 	for _, c := range name {
 		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.') {
-			return false
+			return fmt.Sprintf("%q is not allowed. Use letters, numbers, hyphens, underscores and dots only.", c)
 		}
 	}
-	return true
+	if name == "." || name == ".." {
+		return fmt.Sprintf("%q is a reserved name.", name)
+	}
+	// .github is the one dot-name GitHub gives a meaning to (organization profile and defaults)
+	if strings.HasPrefix(name, ".") && name != DotGithubRepo {
+		return "Repository names cannot start with \".\"."
+	}
+	if strings.HasSuffix(strings.ToLower(name), ".git") {
+		return "Repository names cannot end in \".git\"."
+	}
+	return ""
+}
+
+// normalizeRepoName turns free text such as "My Cool Repo" into a name GitHub accepts, e.g. "My-Cool-Repo"
+// Valid names are returned unchanged; "" means no name could be derived
+func normalizeRepoName(text string) string {
+	text = strings.TrimSpace(text)
+	if repoNameProblem(text) == "" {
+		return text
+	}
+
+	// Like GitHub, replace anything else with hyphens, but only one per run
+	name := invalidRepoNameChars.ReplaceAllString(text, "-")
+	name = repeatedHyphens.ReplaceAllString(name, "-")
+	if len(name) > MaxRepoNameLength {
+		name = name[:MaxRepoNameLength]
+	}
+	for strings.HasSuffix(strings.ToLower(name), ".git") {
+		name = name[:len(name)-len(".git")]
+	}
+	if name != DotGithubRepo {
+		name = strings.TrimLeft(name, ".")
+	}
+	name = strings.Trim(name, "-")
+
+	if repoNameProblem(name) != "" {
+		return ""
+	}
+	return name
 }
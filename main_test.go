@@ -2,10 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"log"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/slack-go/slack"
 )
 
 // TestLoggerLevels tests that log levels are properly filtered
@@ -119,6 +124,22 @@ func TestIsValidRepoName(t *testing.T) {
 		{"Invalid_Empty", "", false},
 		{"Invalid_TooLong", strings.Repeat("a", 101), false},
 		{"Valid_MaxLength", strings.Repeat("a", 100), true},
+		{"Invalid_Dot", ".", false},
+		{"Invalid_DotDot", "..", false},
+		{"Invalid_ThreeDots", "...", false},
+		{"Invalid_LeadingDot", ".hidden", false},
+		{"Valid_DotGithub", ".github", true},
+		{"Valid_TrailingDot", "repo.", true},
+		{"Invalid_GitSuffix", "my-repo.git", false},
+		{"Invalid_GitSuffixUpper", "my-repo.Git", false},
+		{"Invalid_OnlyGitSuffix", ".git", false},
+		{"Invalid_MaxLengthGitSuffix", strings.Repeat("a", 96) + ".git", false},
+		{"Valid_GitWithoutDot", "my-repo-git", true},
+		{"Valid_GitInMiddle", "my.git.repo", true},
+		{"Valid_GitLonger", "my-repo.gitx", true},
+		{"Invalid_Slash", "org/repo", false},
+		{"Invalid_NonASCII", "café", false},
+		{"Invalid_Newline", "repo\n", false},
 	}
 
 	for _, tt := range tests {
@@ -130,3 +151,526 @@ func TestIsValidRepoName(t *testing.T) {
 		})
 	}
 }
+
+// TestValidateRepoName tests that invalid names produce a message for the modal
+func TestValidateRepoName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantMsg string
+	}{
+		{"Valid", "my-awesome-repo", ""},
+		{"Empty", "", "Please enter a repository name."},
+		{"TooLong", strings.Repeat("a", 101), "Repository names must be 100 characters or fewer."},
+		{"InvalidChar", "my repo", `' ' is not allowed. Use letters, numbers, hyphens, underscores and dots only. Try "my-repo".`},
+		{"InvalidCharNoSuggestion", "✅", "'✅' is not allowed. Use letters, numbers, hyphens, underscores and dots only."},
+		{"ReservedDot", ".", `"." is a reserved name.`},
+		{"ReservedDotDot", "..", `".." is a reserved name.`},
+		{"GitSuffix", "my-repo.git", `Repository names cannot end in ".git". Try "my-repo".`},
+		{"GitSuffixUpper", "my-repo.GIT", `Repository names cannot end in ".git". Try "my-repo".`},
+		{"LeadingDot", ".env", `Repository names cannot start with ".". Try "env".`},
+		{"DotGithub", ".github", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateRepoName(tt.input); got != tt.wantMsg {
+				t.Errorf("validateRepoName(%q) = %q, want %q", tt.input, got, tt.wantMsg)
+			}
+		})
+	}
+}
+
+// TestCopilotIssueTitle tests that the issue title is taken from the first line of the prompt
+func TestCopilotIssueTitle(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt string
+		want   string
+	}{
+		{"SingleLine", "Build a simple Go service", "Build a simple Go service"},
+		{"MultiLine", "Build a CLI\nIt should read stdin", "Build a CLI"},
+		{"LeadingBlankLines", "\n\n  Build a CLI  \nmore", "Build a CLI"},
+		{"Truncated", strings.Repeat("a", 100), strings.Repeat("a", MaxIssueTitleLength-1) + "…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := copilotIssueTitle(tt.prompt); got != tt.want {
+				t.Errorf("copilotIssueTitle(%q) = %q, want %q", tt.prompt, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBuildCopilotIssueCommands tests that the issue is labelled and assigned to Copilot
+func TestBuildCopilotIssueCommands(t *testing.T) {
+	commands := renderCommands(buildCopilotIssueCommands("my-org/my-repo", "Build it's thing\nDetails")...)
+	if len(commands) != 2 {
+		t.Fatalf("Expected 2 commands, got %d: %v", len(commands), commands)
+	}
+
+	if !strings.HasPrefix(commands[0], "gh label create copilot --repo my-org/my-repo") {
+		t.Errorf("Unexpected label command: %s", commands[0])
+	}
+
+	want := `gh issue create --repo my-org/my-repo --title 'Build it'\''s thing' --body 'Build it'\''s thing
+Details' --label copilot --assignee @copilot`
+	if commands[1] != want {
+		t.Errorf("Unexpected issue command:\n got: %s\nwant: %s", commands[1], want)
+	}
+}
+
+// TestSendNewRepoConfirmation tests that the Copilot issue is linked by label rather than by number
+func TestSendNewRepoConfirmation(t *testing.T) {
+	service, _ := newTestService(t, &Config{SlackChannelNewRepo: "#new-repo"})
+	request := &RepoRequest{Repo: "my-org/my-repo", RequestedBy: "U1", HasIssue: true}
+	service.sendNewRepoConfirmation(context.Background(), service.Configs.Get(), request)
+
+	messages := service.Notifier.(*fakeNotifier).Messages()
+	if len(messages) != 1 || messages[0].Channel != "#new-repo" {
+		t.Fatalf("Expected one confirmation in #new-repo, got %+v", messages)
+	}
+	if want := "<https://github.com/my-org/my-repo/issues?q=label%3Acopilot|copilot issues>"; !strings.Contains(messages[0].Text, want) {
+		t.Errorf("Expected the confirmation to contain %q, got %q", want, messages[0].Text)
+	}
+	if strings.Contains(messages[0].Text, "/issues/1") {
+		t.Errorf("Expected no link to a numbered issue, got %q", messages[0].Text)
+	}
+}
+
+// TestExtractViewValues tests that plain values and selected options are both extracted
+func TestExtractViewValues(t *testing.T) {
+	payload := `{
+		"type": "view_submission",
+		"view": {
+			"callback_id": "create_github_repo_modal",
+			"state": {
+				"values": {
+					"repo-name": {"repo_name_input": {"type": "plain_text_input", "value": "my-repo"}},
+					"repo-description": {"repo_desc_input": {"type": "plain_text_input", "value": null}},
+					"repo-visibility": {"repo_visibility_select": {"type": "static_select", "selected_option": {"text": {"type": "plain_text", "text": "Private"}, "value": "private"}}},
+					"repo-license": {"repo_license_select": {"type": "static_select", "selected_option": null}}
+				}
+			}
+		}
+	}`
+
+	var submission ViewSubmissionPayload
+	if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+
+	values := extractViewValues(submission)
+	want := map[string]string{
+		"repo-name":        "my-repo",
+		"repo-description": "",
+		"repo-visibility":  "private",
+		"repo-license":     "",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("extractViewValues() = %v, want %v", values, want)
+	}
+}
+
+// TestSelectedOption tests defaults for missing selections and rejection of unknown values
+func TestSelectedOption(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"Missing", map[string]string{}, DefaultVisibility, false},
+		{"Empty", map[string]string{"repo-visibility": ""}, DefaultVisibility, false},
+		{"Known", map[string]string{"repo-visibility": "internal"}, "internal", false},
+		{"Unknown", map[string]string{"repo-visibility": "public; rm -rf /"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectedOption(tt.values, "repo-visibility", repoVisibilities, DefaultVisibility)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("selectedOption() = %q, %v, want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+			if err != nil && err.BlockID != "repo-visibility" {
+				t.Errorf("Expected error for block repo-visibility, got %s", err.BlockID)
+			}
+		})
+	}
+}
+
+// TestBuildRepoCreateCommand tests that the selected options map to gh repo create flags
+func TestBuildRepoCreateCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		desc       string
+		visibility string
+		gitignore  string
+		license    string
+		want       string
+	}{
+		{"Defaults", "", DefaultVisibility, DefaultGitignore, DefaultLicense, "gh repo create org/repo --public --add-readme --gitignore Go"},
+		{"PrivateNoTemplates", "", "private", NoTemplate, NoTemplate, "gh repo create org/repo --private --add-readme"},
+		{"InternalWithLicense", "It's new", "internal", "Python", "mit", `gh repo create org/repo --internal --add-readme --gitignore Python --license mit --description 'It'\''s new'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildRepoCreateCommand("org/repo", tt.desc, tt.visibility, tt.gitignore, tt.license).String()
+			if got != tt.want {
+				t.Errorf("buildRepoCreateCommand() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCreateNewRepoModalSelects tests that the select blocks are present with their defaults
+func TestCreateNewRepoModalSelects(t *testing.T) {
+	modal := createNewRepoModal("my-repo", nil, "", nil)
+
+	want := map[string]string{
+		"repo-visibility": DefaultVisibility,
+		"repo-gitignore":  DefaultGitignore,
+		"repo-license":    DefaultLicense,
+	}
+	for _, block := range modal.Blocks.BlockSet {
+		input, ok := block.(*slack.InputBlock)
+		if !ok {
+			continue
+		}
+		initial, ok := want[input.BlockID]
+		if !ok {
+			continue
+		}
+		selectElement, ok := input.Element.(*slack.SelectBlockElement)
+		if !ok {
+			t.Errorf("Block %s is not a select element", input.BlockID)
+			continue
+		}
+		if selectElement.InitialOption == nil || selectElement.InitialOption.Value != initial {
+			t.Errorf("Block %s initial option = %+v, want %s", input.BlockID, selectElement.InitialOption, initial)
+		}
+		delete(want, input.BlockID)
+	}
+	if len(want) > 0 {
+		t.Errorf("Missing select blocks: %v", want)
+	}
+}
+
+// TestCreateNewRepoModalOrgSelect tests that the organization select is first and pre-selects the default
+func TestCreateNewRepoModalOrgSelect(t *testing.T) {
+	tests := []struct {
+		name       string
+		defaultOrg string
+		want       string
+	}{
+		{"Default", "org-b", "org-b"},
+		{"DefaultNotPermitted", "org-c", "org-a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modal := createNewRepoModal("", []string{"org-a", "org-b"}, tt.defaultOrg, nil)
+			input, ok := modal.Blocks.BlockSet[0].(*slack.InputBlock)
+			if !ok || input.BlockID != "repo-org" {
+				t.Fatalf("Expected the organization select first, got %+v", modal.Blocks.BlockSet[0])
+			}
+			selectElement := input.Element.(*slack.SelectBlockElement)
+			if len(selectElement.Options) != 2 || selectElement.InitialOption == nil || selectElement.InitialOption.Value != tt.want {
+				t.Errorf("Unexpected organization select: %+v", selectElement)
+			}
+		})
+	}
+
+	for _, block := range createNewRepoModal("", nil, "org-a", nil).Blocks.BlockSet {
+		if input, ok := block.(*slack.InputBlock); ok && input.BlockID == "repo-org" {
+			t.Error("Expected no organization select without organizations")
+		}
+	}
+}
+
+// TestCreateNewRepoModalTemplateSelect tests the template select is offered only with templates
+func TestCreateNewRepoModalTemplateSelect(t *testing.T) {
+	templates := []RepoTemplate{{Name: "go-service", Label: "Go service", Repo: "my-org/go-service-template"}}
+
+	var selectElement *slack.SelectBlockElement
+	for _, block := range createNewRepoModal("", nil, "my-org", templates).Blocks.BlockSet {
+		if input, ok := block.(*slack.InputBlock); ok && input.BlockID == "repo-template" {
+			selectElement = input.Element.(*slack.SelectBlockElement)
+		}
+	}
+	if selectElement == nil {
+		t.Fatal("Expected a template select")
+	}
+	if len(selectElement.Options) != 2 || selectElement.Options[1].Value != "go-service" || selectElement.InitialOption.Value != BlankTemplate {
+		t.Errorf("Unexpected template select: %+v", selectElement)
+	}
+
+	for _, block := range createNewRepoModal("", nil, "my-org", nil).Blocks.BlockSet {
+		if input, ok := block.(*slack.InputBlock); ok && input.BlockID == "repo-template" {
+			t.Error("Expected no template select without templates")
+		}
+	}
+}
+
+// TestHandleViewSubmissionRequester tests that the submitting user is attributed and made an admin
+func TestHandleViewSubmissionRequester(t *testing.T) {
+	var submission ViewSubmissionPayload
+	payload := `{"type":"view_submission","team":{"id":"T1","domain":"acme"},
+		"user":{"id":"U1","username":"jdoe","name":"jdoe","team_id":"T1"},
+		"view":{"id":"V1","callback_id":"create_github_repo_modal","state":{"values":{
+		"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"tool"}}}}}}`
+	if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+
+	config := &Config{
+		GithubOrg:           "my-org",
+		GithubUsers:         map[string]string{"U1": "octocat"},
+		SlackChannelNewRepo: "#new-repo",
+	}
+	service, _ := newTestService(t, config)
+	queue := service.Queue.(*fakeQueue)
+	notifier := service.Notifier.(*fakeNotifier)
+	response, err := service.handleViewSubmission(context.Background(), &submission)
+	if err != nil || response != nil {
+		t.Fatalf("Expected the request to be queued, got %+v, %v", response, err)
+	}
+
+	if len(queue.Commands()) != 1 {
+		t.Fatalf("Expected a Poppit command, got %d", len(queue.Commands()))
+	}
+	cmd := queue.Commands()[0]
+	wantMetadata := &PoppitMetadata{UserID: "U1", UserName: "jdoe", TeamID: "T1", TeamDomain: "acme"}
+	if !reflect.DeepEqual(cmd.Metadata, wantMetadata) {
+		t.Errorf("Unexpected metadata: %+v", cmd.Metadata)
+	}
+	wantCommand := "gh api --method PUT repos/my-org/tool/collaborators/octocat -f permission=admin"
+	if len(cmd.Commands) < 2 || cmd.Commands[1] != wantCommand {
+		t.Errorf("Expected %q straight after the create command, got %q", wantCommand, cmd.Commands)
+	}
+
+	messages := notifier.Messages()
+	if len(messages) != 1 || messages[0].Channel != "#new-repo" {
+		t.Fatalf("Expected a confirmation message, got %+v", messages)
+	}
+	if !strings.Contains(messages[0].Text, "*Requested by:* <@U1>") {
+		t.Errorf("Expected the confirmation to name the requester, got %q", messages[0].Text)
+	}
+
+	// Without a GitHub login nobody is added
+	submission.View.ID = "V2"
+	config.GithubUsers = nil
+	if _, err := service.handleViewSubmission(context.Background(), &submission); err != nil {
+		t.Fatalf("handleViewSubmission() failed: %v", err)
+	}
+	if commands := queue.Commands(); len(commands) != 2 || strings.Contains(strings.Join(commands[1].Commands, "\n"), "collaborators") {
+		t.Errorf("Expected a second command without a collaborator, got %+v", commands)
+	}
+}
+
+// viewSubmissionSeeds are payloads the fuzz targets start from
+var viewSubmissionSeeds = []string{
+	`{}`,
+	`null`,
+	`{"type":"view_submission","view":{"id":"V1","callback_id":"create_github_repo_modal","state":{"values":{"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"my-repo"}}}}}}`,
+	`{"view":{"state":{"values":{"repo-visibility":{"repo_visibility_select":{"type":"static_select","selected_option":{"value":"private"}}}}}}}`,
+	`{"view":{"state":{"values":{"repo-name":{"a":{"value":"one"},"b":{"value":"two"}},"empty":{}}}}}`,
+	`{"view":{"state":{"values":{"repo-name":{"a":{"selected_option":null,"value":".git"}}}}}}`,
+	`{"view":{"state":{"values":{"repo-name":{"a":{"value":"it's $(rm -rf /)"}}}}}}`,
+}
+
+// FuzzExtractViewValues checks that any parseable payload yields values taken from its own state
+func FuzzExtractViewValues(f *testing.F) {
+	for _, seed := range viewSubmissionSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, payload string) {
+		var submission ViewSubmissionPayload
+		if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+			t.Skip()
+		}
+
+		values := extractViewValues(submission)
+		for blockID, blockValues := range submission.View.State.Values {
+			value, ok := values[blockID]
+			if ok != (len(blockValues) > 0) {
+				t.Fatalf("Block %q with %d actions extracted: %v", blockID, len(blockValues), ok)
+			}
+			if !ok {
+				continue
+			}
+			// A block normally has one action; with more, any one of them may be taken
+			found := false
+			for _, valueObj := range blockValues {
+				if valueObj.SelectedOption != nil {
+					found = found || valueObj.SelectedOption.Value == value
+				} else {
+					found = found || valueObj.Value == value
+				}
+			}
+			if !found {
+				t.Fatalf("Block %q extracted %q, which is not one of its values", blockID, value)
+			}
+		}
+		if len(values) > len(submission.View.State.Values) {
+			t.Fatalf("Extracted %d values from %d blocks", len(values), len(submission.View.State.Values))
+		}
+
+		// Whatever name is extracted, validation must decide without panicking
+		validateRepoName(values["repo-name"])
+	})
+}
+
+// FuzzRouterHandleViewSubmission checks that the router never panics and dispatches each valid payload once
+func FuzzRouterHandleViewSubmission(f *testing.F) {
+	for _, seed := range viewSubmissionSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, payload string) {
+		handled := 0
+		router := NewCommandRouter(NewLogger("error"), &fakeViewResponder{}, nil)
+		err := router.Register(&SlashCommand{
+			Name:          "/new-repo",
+			HandleCommand: func(ctx context.Context, cmd *SlashCommandPayload) {},
+			CallbackIDs:   []string{"create_github_repo_modal"},
+			ErrorBlockID:  "repo-name",
+			HandleViewSubmission: func(ctx context.Context, submission *ViewSubmissionPayload) (*slack.ViewSubmissionResponse, error) {
+				handled++
+				return nil, nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to register command: %v", err)
+		}
+
+		err = router.HandleViewSubmission(context.Background(), payload)
+		var submission ViewSubmissionPayload
+		if json.Unmarshal([]byte(payload), &submission) != nil {
+			if err == nil {
+				t.Fatal("Expected a parse error for a payload that is not JSON")
+			}
+			return
+		}
+		want := 0
+		if submission.View.CallbackID == "create_github_repo_modal" {
+			want = 1
+		}
+		if err != nil || handled != want {
+			t.Fatalf("Expected %d calls and no error, got %d calls and %v", want, handled, err)
+		}
+	})
+}
+
+// FuzzValidateRepoName checks that every accepted name follows GitHub's rules and is safe in a command
+func FuzzValidateRepoName(f *testing.F) {
+	for _, seed := range []string{"my-repo", ".", "..", "...", "repo.git", "repo.GIT", "My-Repo_2.0", "café", "a b", "$(x)"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		message := validateRepoName(name)
+		if message != "" {
+			return
+		}
+		if name == "" || len(name) > MaxRepoNameLength {
+			t.Fatalf("Accepted %q with length %d", name, len(name))
+		}
+		if name == "." || name == ".." || strings.HasSuffix(strings.ToLower(name), ".git") || (name[0] == '.' && name != DotGithubRepo) {
+			t.Fatalf("Accepted a name GitHub rejects: %q", name)
+		}
+		// Names go into commands and URLs unquoted
+		if shellWord(name) != name {
+			t.Fatalf("Accepted %q, which needs quoting", name)
+		}
+	})
+}
+
+// TestNormalizeRepoName tests that free text becomes a name GitHub accepts
+func TestNormalizeRepoName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Valid", "my-repo", "my-repo"},
+		{"ValidKeepsRepeatedHyphens", "my--repo", "my--repo"},
+		{"Spaces", "My Cool Repo", "My-Cool-Repo"},
+		{"SurroundingSpace", "  my repo  ", "my-repo"},
+		{"Punctuation", "Tom's app (v2)!", "Tom-s-app-v2"},
+		{"HyphenRuns", "a - b -- c", "a-b-c"},
+		{"NonASCII", "café ✅ bar", "caf-bar"},
+		{"GitSuffix", "my-repo.git", "my-repo"},
+		{"GitSuffixes", "my repo.git.GIT", "my-repo"},
+		{"LeadingDots", "..hidden files", "hidden-files"},
+		{"DotGithub", ".github", ".github"},
+		{"Truncated", strings.Repeat("ab ", 50), strings.Repeat("ab-", 33) + "a"},
+		{"TruncatedGitSuffix", strings.Repeat("a", 96) + ".git and more", strings.Repeat("a", 96)},
+		{"Empty", "", ""},
+		{"NothingLeft", "✅ !!", ""},
+		{"OnlyDots", "..", ""},
+		{"OnlyGit", ".git", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeRepoName(tt.input); got != tt.want {
+				t.Errorf("normalizeRepoName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCreateNewRepoModalSuggestsName tests that the command text is pre-filled as a valid name
+func TestCreateNewRepoModalSuggestsName(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"My Cool Repo", "My-Cool-Repo"},
+		{"my-repo", "my-repo"},
+		{"", ""},
+		{"✅", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			modal := createNewRepoModal(tt.text, nil, "my-org", nil)
+			for _, block := range modal.Blocks.BlockSet {
+				if input, ok := block.(*slack.InputBlock); ok && input.BlockID == "repo-name" {
+					if got := input.Element.(*slack.PlainTextInputBlockElement).InitialValue; got != tt.want {
+						t.Errorf("Initial name = %q, want %q", got, tt.want)
+					}
+					return
+				}
+			}
+			t.Fatal("Expected a repo-name block")
+		})
+	}
+}
+
+// FuzzNormalizeRepoName checks that a suggestion is always valid, and stable once made
+func FuzzNormalizeRepoName(f *testing.F) {
+	for _, seed := range []string{"My Cool Repo", "my-repo", ".github", "..hidden", "repo.git.git", "café ✅", strings.Repeat("a b", 60)} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, text string) {
+		name := normalizeRepoName(text)
+		if name == "" {
+			return
+		}
+		if message := validateRepoName(name); message != "" {
+			t.Fatalf("normalizeRepoName(%q) = %q, which is invalid: %s", text, name, message)
+		}
+		if again := normalizeRepoName(name); again != name {
+			t.Fatalf("normalizeRepoName(%q) = %q, but normalizing that gives %q", text, name, again)
+		}
+		if isValidRepoName(text) && name != text {
+			t.Fatalf("normalizeRepoName changed the valid name %q to %q", text, name)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// metricsNamespace prefixes every metric exported by the service
+const metricsNamespace = "slashviberepo"
+
+// Label values used when a command or callback ID is not owned by this service
+const otherLabel = "other"
+
+// Push targets recorded on pushErrorsTotal
+const (
+	PushTargetPoppit       = "poppit"
+	PushTargetSlackLiner   = "slackliner"
+	PushTargetViewResponse = "view_response"
+	PushTargetDeadLetter   = "dead_letter"
+)
+
+// Reasons recorded on validationFailuresTotal
+const (
+	ValidationInvalidName   = "invalid_name"
+	ValidationInvalidOption = "invalid_option"
+	ValidationRepoExists    = "repo_exists"
+)
+
+// Slack API methods recorded on slackAPIErrorsTotal
+const (
+	SlackMethodViewsOpen   = "views.open"
+	SlackMethodResponseURL = "response_url"
+	SlackMethodAuthTest    = "auth.test"
+	// SlackMethodChatPostMessage is used to post approval requests
+	SlackMethodChatPostMessage = "chat.postMessage"
+	// SlackMethodUsergroupsUsersList is used to resolve user groups in the authorization policy
+	SlackMethodUsergroupsUsersList = "usergroups.users.list"
+)
+
+var (
+	slashCommandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "slash_commands_total",
+		Help:      "Slash commands received, by command.",
+	}, []string{"command"})
+
+	viewSubmissionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "view_submissions_total",
+		Help:      "View submissions received, by callback ID.",
+	}, []string{"callback_id"})
+
+	blockActionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "block_actions_total",
+		Help:      "Block actions (e.g. button clicks) received, by action ID.",
+	}, []string{"action_id"})
+
+	approvalsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "approvals_total",
+		Help:      "Repository approval requests, by decision.",
+	}, []string{"decision"})
+
+	validationFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "validation_failures_total",
+		Help:      "View submissions rejected with a validation error, by reason.",
+	}, []string{"reason"})
+
+	rateLimitRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Repository requests rejected by a rate limit, by scope.",
+	}, []string{"scope"})
+
+	pushErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "push_errors_total",
+		Help:      "Failed pushes to Redis lists, by target.",
+	}, []string{"target"})
+
+	rejectedPayloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rejected_payloads_total",
+		Help:      "Payloads that failed verification, by payload type and reason.",
+	}, []string{"payload", "reason"})
+
+	authorizationDenialsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "authorization_denials_total",
+		Help:      "Users denied by the authorization policy, by role and stage.",
+	}, []string{"role", "stage"})
+
+	slackAPIErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "slack_api_errors_total",
+		Help:      "Failed Slack API calls, by method.",
+	}, []string{"method"})
+
+	handlerDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "handler_duration_seconds",
+		Help:      "Time taken to handle a message, by handler.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler"})
+
+	openViewDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "slack_open_view_duration_seconds",
+		Help:      "Time taken by the Slack views.open API call.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+// metricsRegistry holds the service metrics plus the standard Go and process collectors
+var metricsRegistry = newMetricsRegistry()
+
+func newMetricsRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		slashCommandsTotal,
+		viewSubmissionsTotal,
+		blockActionsTotal,
+		approvalsTotal,
+		validationFailuresTotal,
+		rateLimitRejectionsTotal,
+		pushErrorsTotal,
+		rejectedPayloadsTotal,
+		authorizationDenialsTotal,
+		slackAPIErrorsTotal,
+		handlerDurationSeconds,
+		openViewDurationSeconds,
+	)
+	return registry
+}
+
+// observeHandlerDuration records the time since start for handler; use with defer
+func observeHandlerDuration(handler string, start time.Time) {
+	handlerDurationSeconds.WithLabelValues(handler).Observe(time.Since(start).Seconds())
+}
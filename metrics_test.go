@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/slack-go/slack"
+)
+
+// TestMetricsEndpoint tests that /metrics serves the service metrics
+func TestMetricsEndpoint(t *testing.T) {
+	// Vectors are only exported once they have a labelled child
+	slashCommandsTotal.WithLabelValues(otherLabel)
+	handlerDurationSeconds.WithLabelValues("slash_command")
+
+	server := httptest.NewServer(newHTTPHandler(NewHealthChecker(nil, nil)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	for _, want := range []string{
+		"slashviberepo_slash_commands_total",
+		"slashviberepo_handler_duration_seconds_bucket",
+		"slashviberepo_slack_open_view_duration_seconds_count",
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected /metrics to contain %s", want)
+		}
+	}
+}
+
+// TestCommandRouterMetrics tests that the router counts commands and view submissions by name
+func TestCommandRouterMetrics(t *testing.T) {
+	router := NewCommandRouter(NewLogger("error"), &fakeViewResponder{}, nil)
+	err := router.Register(&SlashCommand{
+		Name:          "/metrics-test",
+		CallbackIDs:   []string{"metrics_test_modal"},
+		ErrorBlockID:  "repo-name",
+		HandleCommand: func(ctx context.Context, cmd *SlashCommandPayload) {},
+		HandleViewSubmission: func(ctx context.Context, submission *ViewSubmissionPayload) (*slack.ViewSubmissionResponse, error) {
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register command: %v", err)
+	}
+
+	commands := slashCommandsTotal.WithLabelValues("/metrics-test")
+	unknownCommands := slashCommandsTotal.WithLabelValues(otherLabel)
+	submissions := viewSubmissionsTotal.WithLabelValues("metrics_test_modal")
+	unknownSubmissions := viewSubmissionsTotal.WithLabelValues(otherLabel)
+
+	before := []float64{
+		testutil.ToFloat64(commands),
+		testutil.ToFloat64(unknownCommands),
+		testutil.ToFloat64(submissions),
+		testutil.ToFloat64(unknownSubmissions),
+	}
+
+	ctx := context.Background()
+	router.HandleMessage(ctx, `{"command":"/metrics-test"}`)
+	router.HandleMessage(ctx, `{"command":"/unknown"}`)
+	router.HandleViewSubmission(ctx, `{"view":{"id":"V1","callback_id":"metrics_test_modal"}}`)
+	router.HandleViewSubmission(ctx, `{"view":{"id":"V2","callback_id":"someone_elses_modal"}}`)
+
+	after := []float64{
+		testutil.ToFloat64(commands),
+		testutil.ToFloat64(unknownCommands),
+		testutil.ToFloat64(submissions),
+		testutil.ToFloat64(unknownSubmissions),
+	}
+	for i := range before {
+		if after[i]-before[i] != 1 {
+			t.Errorf("Expected counter %d to increase by 1, got %v -> %v", i, before[i], after[i])
+		}
+	}
+}
+
+// TestHandleViewSubmissionValidationMetrics tests that rejected submissions are counted by reason
+func TestHandleViewSubmissionValidationMetrics(t *testing.T) {
+	counter := validationFailuresTotal.WithLabelValues(ValidationInvalidName)
+	before := testutil.ToFloat64(counter)
+
+	var submission ViewSubmissionPayload
+	payload := `{"type":"view_submission","view":{"id":"V1","callback_id":"create_github_repo_modal","state":{"values":{
+		"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"bad name!"}}}}}}`
+	if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+
+	config := &Config{GithubOrg: "org"}
+	service := &Service{Logger: NewLogger("error"), Configs: NewConfigStore(config), RepoChecker: &fakeRepoChecker{}, Authorizer: &fakeAuthorizer{}}
+	resp, err := service.handleViewSubmission(context.Background(), &submission)
+	if err != nil || resp == nil {
+		t.Fatalf("Expected a validation response, got %+v, %v", resp, err)
+	}
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("Expected %s failures to increase by 1, got %v", ValidationInvalidName, got)
+	}
+}
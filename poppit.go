@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// PoppitNewRepoType is the Poppit command type used for new repository requests
+	PoppitNewRepoType = "slash-vibe-new-repo"
+	// PendingRequestKeyPrefix is the Redis key prefix for requests awaiting Poppit results
+	PendingRequestKeyPrefix = "slashviberepo:pending"
+	// PendingRequestTTL is how long a request waits for its Poppit results before being forgotten
+	PendingRequestTTL = 24 * time.Hour
+	// MaxStderrExcerptLength is the maximum number of characters of stderr included in a failure message
+	MaxStderrExcerptLength = 500
+)
+
+// PoppitOutput represents the result of a single command published by Poppit
+type PoppitOutput struct {
+	CorrelationID string `json:"correlation_id"`
+	Type          string `json:"type"`
+	Repo          string `json:"repo"`
+	Command       string `json:"command"`
+	Status        string `json:"status"`
+	ExitCode      int    `json:"exit_code"`
+	Output        string `json:"output"`
+	Stderr        string `json:"stderr"`
+}
+
+// Failed reports whether the command failed
+func (o *PoppitOutput) Failed() bool {
+	return o.ExitCode != 0 || strings.EqualFold(o.Status, "failure") || strings.EqualFold(o.Status, "error")
+}
+
+// PendingRequest is a queued Poppit command whose outcome has not been reported yet
+type PendingRequest struct {
+	Repo        string    `json:"repo"`
+	Commands    []string  `json:"commands"`
+	Channel     string    `json:"channel"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// newCorrelationID returns a random ID used to match Poppit results to requests
+func newCorrelationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(fmt.Sprintf("failed to generate correlation ID: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+func pendingRequestKey(correlationID string) string {
+	return fmt.Sprintf("%s:%s", PendingRequestKeyPrefix, correlationID)
+}
+
+// storePendingRequest records a request so its Poppit results can be reported later
+func storePendingRequest(ctx context.Context, redisClient *redis.Client, correlationID string, pending *PendingRequest) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending request: %w", err)
+	}
+	return redisClient.Set(ctx, pendingRequestKey(correlationID), data, PendingRequestTTL).Err()
+}
+
+// handlePoppitOutput matches a Poppit result to its request and reports the final outcome
+func (s *Service) handlePoppitOutput(ctx context.Context, payload string) {
+	defer observeHandlerDuration("poppit_output", time.Now())
+	s.Logger.Debug("Received Poppit output", "payload", payload)
+
+	var output PoppitOutput
+	if err := json.Unmarshal([]byte(payload), &output); err != nil {
+		s.Logger.Error("Failed to unmarshal Poppit output", "error", err)
+		return
+	}
+
+	// Poppit output is shared with other services, so only look at our own requests
+	if output.Type != PoppitNewRepoType || output.CorrelationID == "" {
+		s.Logger.Debug("Ignoring Poppit output", "type", output.Type)
+		return
+	}
+
+	key := pendingRequestKey(output.CorrelationID)
+	data, err := s.Redis.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		s.Logger.Debug("No pending request for Poppit output", "correlation_id", output.CorrelationID)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("Failed to load pending request", "correlation_id", output.CorrelationID, "error", err)
+		return
+	}
+
+	var pending PendingRequest
+	if err := json.Unmarshal([]byte(data), &pending); err != nil {
+		s.Logger.Error("Failed to unmarshal pending request", "correlation_id", output.CorrelationID, "error", err)
+		return
+	}
+
+	var text string
+	switch {
+	case output.Failed():
+		text = formatPoppitFailure(&pending, &output)
+	case len(pending.Commands) > 0 && output.Command == pending.Commands[len(pending.Commands)-1]:
+		text = formatPoppitSuccess(&pending)
+	default:
+		s.Logger.Debug("Command succeeded, waiting for remaining commands", "repo", pending.Repo, "correlation_id", output.CorrelationID, "poppit_command", output.Command)
+		return
+	}
+
+	// Only the first final result is reported; a missing key means another result got there first
+	deleted, err := s.Redis.Del(ctx, key).Result()
+	if err != nil {
+		s.Logger.Error("Failed to delete pending request", "correlation_id", output.CorrelationID, "error", err)
+		return
+	}
+	if deleted == 0 {
+		return
+	}
+
+	s.Logger.Info("Poppit finished", "repo", pending.Repo, "correlation_id", output.CorrelationID, "failed", output.Failed())
+	s.notify(ctx, pending.Channel, text)
+}
+
+// formatPoppitSuccess builds the message reporting a successfully created repository
+func formatPoppitSuccess(pending *PendingRequest) string {
+	repoURL := fmt.Sprintf("https://github.com/%s", pending.Repo)
+	return fmt.Sprintf("🎉 Repository <%s|%s> is ready!", repoURL, pending.Repo)
+}
+
+// formatPoppitFailure builds the message reporting the command that failed and its stderr
+func formatPoppitFailure(pending *PendingRequest, output *PoppitOutput) string {
+	text := fmt.Sprintf("❌ Repository creation failed for *%s*\n\n*Command:* `%s`\n*Exit code:* %d",
+		pending.Repo, output.Command, output.ExitCode)
+
+	if excerpt := stderrExcerpt(output.Stderr); excerpt != "" {
+		text = fmt.Sprintf("%s\n*Error:*\n```%s```", text, excerpt)
+	}
+	return text
+}
+
+// stderrExcerpt returns the tail of stderr, where errors are usually reported
+func stderrExcerpt(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	// Stop stderr from closing the surrounding code block early
+	stderr = strings.ReplaceAll(stderr, "```", "'''")
+
+	runes := []rune(stderr)
+	if len(runes) > MaxStderrExcerptLength {
+		stderr = "…" + string(runes[len(runes)-MaxStderrExcerptLength+1:])
+	}
+	return stderr
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestPoppitOutputFailed tests that failures are detected from the exit code or status
+func TestPoppitOutputFailed(t *testing.T) {
+	tests := []struct {
+		name   string
+		output PoppitOutput
+		want   bool
+	}{
+		{"Success", PoppitOutput{Status: "success", ExitCode: 0}, false},
+		{"NoStatus", PoppitOutput{ExitCode: 0}, false},
+		{"NonZeroExit", PoppitOutput{Status: "success", ExitCode: 1}, true},
+		{"FailureStatus", PoppitOutput{Status: "failure"}, true},
+		{"ErrorStatus", PoppitOutput{Status: "ERROR"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.output.Failed(); got != tt.want {
+				t.Errorf("Failed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFormatPoppitFailure tests that the failing command and stderr are included
+func TestFormatPoppitFailure(t *testing.T) {
+	pending := &PendingRequest{Repo: "org/repo"}
+	output := &PoppitOutput{
+		Command:  "gh repo create org/repo --public",
+		ExitCode: 1,
+		Stderr:   "GraphQL: Name already exists on this account\n",
+	}
+
+	text := formatPoppitFailure(pending, output)
+	for _, want := range []string{"*org/repo*", "`gh repo create org/repo --public`", "*Exit code:* 1", "```GraphQL: Name already exists on this account```"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected failure message to contain %q, got: %s", want, text)
+		}
+	}
+
+	output.Stderr = ""
+	if text := formatPoppitFailure(pending, output); strings.Contains(text, "*Error:*") {
+		t.Errorf("Expected no error section without stderr, got: %s", text)
+	}
+}
+
+// TestStderrExcerpt tests that long stderr is truncated to its tail and code fences are neutralised
+func TestStderrExcerpt(t *testing.T) {
+	long := strings.Repeat("x", MaxStderrExcerptLength) + "the real error"
+	excerpt := stderrExcerpt(long)
+	if len([]rune(excerpt)) != MaxStderrExcerptLength {
+		t.Errorf("Expected excerpt of %d characters, got %d", MaxStderrExcerptLength, len([]rune(excerpt)))
+	}
+	if !strings.HasPrefix(excerpt, "…") || !strings.HasSuffix(excerpt, "the real error") {
+		t.Errorf("Expected excerpt to keep the tail, got: %s", excerpt)
+	}
+
+	if got := stderrExcerpt("bad ```fence```"); strings.Contains(got, "```") {
+		t.Errorf("Expected code fences to be replaced, got: %s", got)
+	}
+}
+
+// TestNewCorrelationID tests that correlation IDs are unique hex strings
+func TestNewCorrelationID(t *testing.T) {
+	first, second := newCorrelationID(), newCorrelationID()
+	if len(first) != 32 || strings.Trim(first, "0123456789abcdef") != "" {
+		t.Errorf("Expected 32 hex characters, got %q", first)
+	}
+	if first == second {
+		t.Errorf("Expected unique correlation IDs, got %q twice", first)
+	}
+}
+
+// TestHandlePoppitOutput tests which Poppit results are reported for a pending request
+func TestHandlePoppitOutput(t *testing.T) {
+	service, server := newTestService(t, &Config{})
+	notifier := service.Notifier.(*fakeNotifier)
+	ctx := context.Background()
+
+	pending := &PendingRequest{
+		Repo:     "my-org/tool",
+		Commands: []string{"gh repo create my-org/tool --public", "gh repo clone my-org/tool"},
+		Channel:  "#new-repo",
+	}
+	publish := func(output PoppitOutput) {
+		t.Helper()
+		data, err := json.Marshal(output)
+		if err != nil {
+			t.Fatalf("Failed to marshal Poppit output: %v", err)
+		}
+		service.handlePoppitOutput(ctx, string(data))
+	}
+
+	if err := storePendingRequest(ctx, service.Redis, "abc", pending); err != nil {
+		t.Fatalf("storePendingRequest() failed: %v", err)
+	}
+
+	// Output from other services is ignored, even with a matching correlation ID
+	publish(PoppitOutput{CorrelationID: "abc", Type: "other-service", Command: pending.Commands[1], ExitCode: 1})
+	// A command that succeeds before the last one posts nothing
+	publish(PoppitOutput{CorrelationID: "abc", Type: PoppitNewRepoType, Command: pending.Commands[0], Status: "success"})
+	if messages := notifier.Messages(); len(messages) != 0 {
+		t.Fatalf("Expected no messages yet, got %+v", messages)
+	}
+	if !server.Exists(pendingRequestKey("abc")) {
+		t.Fatal("Expected the request to stay pending")
+	}
+
+	// A failure reports the command and the end of its stderr
+	publish(PoppitOutput{
+		CorrelationID: "abc",
+		Type:          PoppitNewRepoType,
+		Command:       pending.Commands[1],
+		Status:        "failure",
+		ExitCode:      128,
+		Stderr:        "Cloning into 'tool'...\nfatal: repository not found",
+	})
+	messages := notifier.Messages()
+	if len(messages) != 1 || messages[0].Channel != "#new-repo" {
+		t.Fatalf("Expected one failure message, got %+v", messages)
+	}
+	for _, want := range []string{"failed for *my-org/tool*", "`gh repo clone my-org/tool`", "*Exit code:* 128", "fatal: repository not found"} {
+		if !strings.Contains(messages[0].Text, want) {
+			t.Errorf("Expected the failure message to contain %q, got %q", want, messages[0].Text)
+		}
+	}
+	if server.Exists(pendingRequestKey("abc")) {
+		t.Error("Expected the pending request to be deleted")
+	}
+
+	// Once reported, later final results for the request are ignored
+	publish(PoppitOutput{CorrelationID: "abc", Type: PoppitNewRepoType, Command: pending.Commands[1], Status: "success"})
+	if messages := notifier.Messages(); len(messages) != 1 {
+		t.Errorf("Expected a second result to be ignored, got %+v", messages)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// RateLimitKeyPrefix is the Redis key prefix for rate limit windows
+	RateLimitKeyPrefix = "slashviberepo:ratelimit"
+)
+
+// Rate limit scopes, recorded on rateLimitRejectionsTotal
+const (
+	RateLimitScopeUser = "user"
+	RateLimitScopeOrg  = "org"
+)
+
+// RateLimit allows Limit requests in any Window
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (l *RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", l.Limit, formatTTL(l.Window))
+}
+
+// parseRateLimit parses a limit such as "5/24h"; an empty value means no limit
+func parseRateLimit(value string) (*RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	count, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return nil, fmt.Errorf("rate limit %q must be <count>/<window>, e.g. 5/24h", value)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || limit <= 0 {
+		return nil, fmt.Errorf("rate limit %q must have a positive count", value)
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("rate limit %q must have a positive window, e.g. 1h", value)
+	}
+	return &RateLimit{Limit: limit, Window: duration}, nil
+}
+
+// slidingWindowScript records a request in every window if none of them is full
+//
+//	KEYS: one sorted set per window, scored by request time in milliseconds
+//	ARGV: now (ms), member (empty to only check), then limit and window (ms) for each key
+//
+// It returns {1, 0, 0} if the request was recorded (or would fit), or {0, index, retry_after_ms}
+// for the first full window (1-based), without recording anything
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local member = ARGV[2]
+
+for i, key in ipairs(KEYS) do
+	local limit = tonumber(ARGV[1 + i * 2])
+	local window = tonumber(ARGV[2 + i * 2])
+	redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+	if redis.call("ZCARD", key) >= limit then
+		local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+		return {0, i, tonumber(oldest[2]) + window - now}
+	end
+end
+if member == "" then
+	return {1, 0, 0}
+end
+
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[2 + i * 2])
+	redis.call("ZADD", key, now, member)
+	redis.call("PEXPIRE", key, window)
+end
+return {1, 0, 0}
+`)
+
+// rateLimitWindow is a single window a request is counted in
+type rateLimitWindow struct {
+	scope string
+	key   string
+	limit *RateLimit
+}
+
+// RateLimitExceeded describes the window that rejected a request
+type RateLimitExceeded struct {
+	Scope      string
+	Org        string
+	Limit      *RateLimit
+	RetryAfter time.Duration
+}
+
+// rateLimitWindows returns the configured windows that apply to userID creating a repository in org
+func rateLimitWindows(config *Config, org, userID string) []rateLimitWindow {
+	var windows []rateLimitWindow
+	if config.RateLimitUser != nil {
+		windows = append(windows, rateLimitWindow{
+			scope: RateLimitScopeUser,
+			key:   fmt.Sprintf("%s:%s:%s", RateLimitKeyPrefix, RateLimitScopeUser, userID),
+			limit: config.RateLimitUser,
+		})
+	}
+	if config.RateLimitOrg != nil {
+		windows = append(windows, rateLimitWindow{
+			scope: RateLimitScopeOrg,
+			key:   fmt.Sprintf("%s:%s:%s", RateLimitKeyPrefix, RateLimitScopeOrg, org),
+			limit: config.RateLimitOrg,
+		})
+	}
+	return windows
+}
+
+// reserveRateLimit records requestID against every configured limit for userID in org
+// It returns nil if the request is within the limits, or the first limit it exceeds;
+// a rejected request is not recorded in any window, and nor is an empty requestID
+func reserveRateLimit(ctx context.Context, redisClient *redis.Client, config *Config, org, userID, requestID string) (*RateLimitExceeded, error) {
+	windows := rateLimitWindows(config, org, userID)
+	if len(windows) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(windows))
+	args := []interface{}{time.Now().UnixMilli(), requestID}
+	for _, window := range windows {
+		keys = append(keys, window.key)
+		args = append(args, window.limit.Limit, window.limit.Window.Milliseconds())
+	}
+
+	result, err := slidingWindowScript.Run(ctx, redisClient, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limits: %w", err)
+	}
+	if len(result) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit result %v", result)
+	}
+	if result[0] == 1 {
+		return nil, nil
+	}
+
+	window := windows[result[1]-1]
+	return &RateLimitExceeded{
+		Scope:      window.scope,
+		Org:        org,
+		Limit:      window.limit,
+		RetryAfter: time.Duration(result[2]) * time.Millisecond,
+	}, nil
+}
+
+// checkRateLimit reports the first limit a new request from userID in org would exceed, without recording one
+// Requests held for approval are checked when submitted but only counted once approved
+func checkRateLimit(ctx context.Context, redisClient *redis.Client, config *Config, org, userID string) (*RateLimitExceeded, error) {
+	return reserveRateLimit(ctx, redisClient, config, org, userID, "")
+}
+
+// releaseRateLimit removes requestID from every window, e.g. when the request could not be queued
+func releaseRateLimit(ctx context.Context, redisClient *redis.Client, config *Config, org, userID, requestID string) error {
+	windows := rateLimitWindows(config, org, userID)
+	if len(windows) == 0 {
+		return nil
+	}
+
+	pipe := redisClient.TxPipeline()
+	for _, window := range windows {
+		pipe.ZRem(ctx, window.key, requestID)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// rateLimitMessage explains which limit a request exceeded and when to try again
+// A user limit is explained to the requester, or about userID if one is given
+func rateLimitMessage(exceeded *RateLimitExceeded, userID string) string {
+	retryAfter := exceeded.RetryAfter.Round(time.Minute)
+	if retryAfter < time.Minute {
+		retryAfter = time.Minute
+	}
+
+	subject := "You have"
+	if userID != "" {
+		subject = fmt.Sprintf("<@%s> has", userID)
+	}
+	if exceeded.Scope == RateLimitScopeOrg {
+		subject = fmt.Sprintf("%s has", exceeded.Org)
+	}
+	return fmt.Sprintf("%s reached the limit of %d new repositories per %s. Please try again in %s.",
+		subject, exceeded.Limit.Limit, formatTTL(exceeded.Limit.Window), formatTTL(retryAfter))
+}
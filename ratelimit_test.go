@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// TestParseRateLimit tests the <count>/<window> format
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    *RateLimit
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"5/24h", &RateLimit{Limit: 5, Window: 24 * time.Hour}, false},
+		{" 20 / 1h30m ", &RateLimit{Limit: 20, Window: 90 * time.Minute}, false},
+		{"5", nil, true},
+		{"0/1h", nil, true},
+		{"-1/1h", nil, true},
+		{"five/1h", nil, true},
+		{"5/day", nil, true},
+		{"5/0s", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRateLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRateLimit(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("parseRateLimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRateLimitMessage tests that the message names the limit and when to try again
+func TestRateLimitMessage(t *testing.T) {
+	user := rateLimitMessage(&RateLimitExceeded{
+		Scope:      RateLimitScopeUser,
+		Limit:      &RateLimit{Limit: 5, Window: 24 * time.Hour},
+		RetryAfter: 3*time.Hour + 12*time.Minute + 5*time.Second,
+	}, "")
+	if want := "You have reached the limit of 5 new repositories per 24h. Please try again in 3h12m."; user != want {
+		t.Errorf("rateLimitMessage() = %q, want %q", user, want)
+	}
+
+	org := rateLimitMessage(&RateLimitExceeded{
+		Scope:      RateLimitScopeOrg,
+		Org:        "my-org",
+		Limit:      &RateLimit{Limit: 20, Window: time.Hour},
+		RetryAfter: 10 * time.Second,
+	}, "U1")
+	if !strings.HasPrefix(org, "my-org has reached") || !strings.HasSuffix(org, "try again in 1m.") {
+		t.Errorf("Unexpected org message: %q", org)
+	}
+
+	about := rateLimitMessage(&RateLimitExceeded{
+		Scope:      RateLimitScopeUser,
+		Limit:      &RateLimit{Limit: 5, Window: 24 * time.Hour},
+		RetryAfter: time.Hour,
+	}, "U1")
+	if !strings.HasPrefix(about, "<@U1> has reached the limit") {
+		t.Errorf("Expected the message to be about U1, got %q", about)
+	}
+}
+
+// TestReserveRateLimit tests the sliding windows against an in-memory Redis
+func TestReserveRateLimit(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+
+	config := &Config{
+		GithubOrg:     "my-org",
+		RateLimitUser: &RateLimit{Limit: 2, Window: time.Hour},
+		RateLimitOrg:  &RateLimit{Limit: 3, Window: time.Hour},
+	}
+	ctx := context.Background()
+
+	reserve := func(userID, requestID string) *RateLimitExceeded {
+		t.Helper()
+		exceeded, err := reserveRateLimit(ctx, redisClient, config, "my-org", userID, requestID)
+		if err != nil {
+			t.Fatalf("reserveRateLimit(%s, %s) failed: %v", userID, requestID, err)
+		}
+		return exceeded
+	}
+
+	if reserve("U1", "r1") != nil || reserve("U1", "r2") != nil {
+		t.Fatal("Expected the first two requests to be allowed")
+	}
+
+	// Checking reports the full window without recording anything
+	if exceeded, err := checkRateLimit(ctx, redisClient, config, "my-org", "U1"); err != nil || exceeded == nil || exceeded.Scope != RateLimitScopeUser {
+		t.Fatalf("Expected the check to find the user limit reached, got %+v, %v", exceeded, err)
+	}
+	if exceeded, err := checkRateLimit(ctx, redisClient, config, "my-org", "U2"); err != nil || exceeded != nil {
+		t.Fatalf("Expected U2 to be within the limits, got %+v, %v", exceeded, err)
+	}
+
+	exceeded := reserve("U1", "r3")
+	if exceeded == nil || exceeded.Scope != RateLimitScopeUser {
+		t.Fatalf("Expected the user limit to be exceeded, got %+v", exceeded)
+	}
+	if exceeded.RetryAfter <= 0 || exceeded.RetryAfter > time.Hour {
+		t.Errorf("Expected retry after within the window, got %s", exceeded.RetryAfter)
+	}
+
+	// The rejected request was not recorded, so another user can still use the org's last slot
+	if exceeded := reserve("U2", "r4"); exceeded != nil {
+		t.Fatalf("Expected U2 to be allowed, got %+v", exceeded)
+	}
+	if exceeded := reserve("U3", "r5"); exceeded == nil || exceeded.Scope != RateLimitScopeOrg {
+		t.Fatalf("Expected the org limit to be exceeded, got %+v", exceeded)
+	}
+
+	// Each organization has its own window
+	if exceeded, err := reserveRateLimit(ctx, redisClient, config, "other-org", "U3", "r6"); err != nil || exceeded != nil {
+		t.Fatalf("Expected other-org to be allowed, got %+v, %v", exceeded, err)
+	}
+	if err := releaseRateLimit(ctx, redisClient, config, "other-org", "U3", "r6"); err != nil {
+		t.Fatalf("releaseRateLimit failed: %v", err)
+	}
+
+	// Releasing a request frees its slot in every window
+	if err := releaseRateLimit(ctx, redisClient, config, "my-org", "U2", "r4"); err != nil {
+		t.Fatalf("releaseRateLimit failed: %v", err)
+	}
+	if exceeded := reserve("U3", "r5"); exceeded != nil {
+		t.Errorf("Expected U3 to be allowed after the release, got %+v", exceeded)
+	}
+}
+
+// TestReserveRateLimitDisabled tests that no limits means no Redis calls
+func TestReserveRateLimitDisabled(t *testing.T) {
+	exceeded, err := reserveRateLimit(context.Background(), nil, &Config{}, "my-org", "U1", "r1")
+	if err != nil || exceeded != nil {
+		t.Errorf("Expected no limit, got %+v, %v", exceeded, err)
+	}
+}
+
+// TestRateLimitHeldRequests tests that requests held for approval only count once approved
+func TestRateLimitHeldRequests(t *testing.T) {
+	config := approvalTestConfig()
+	config.RateLimitUser = &RateLimit{Limit: 1, Window: 24 * time.Hour}
+	service, server := newTestService(t, config)
+	queue := service.Queue.(*fakeQueue)
+	ctx := context.Background()
+	userKey := RateLimitKeyPrefix + ":user:U1"
+
+	// submit sends a private repository request from U1, which is held for approval
+	submit := func(viewID, name string) *slack.ViewSubmissionResponse {
+		t.Helper()
+		var submission ViewSubmissionPayload
+		payload := `{"type":"view_submission","user":{"id":"U1"},"view":{"id":"` + viewID + `","callback_id":"create_github_repo_modal","state":{"values":{
+			"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"` + name + `"}},
+			"repo-visibility":{"repo_visibility_select":{"type":"static_select","selected_option":{"value":"private"}}}}}}}`
+		if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+			t.Fatalf("Failed to unmarshal payload: %v", err)
+		}
+		response, err := service.handleViewSubmission(ctx, &submission)
+		if err != nil {
+			t.Fatalf("handleViewSubmission() failed: %v", err)
+		}
+		return response
+	}
+	// decide clicks Approve or Reject on the only pending request
+	decide := func(actionID string) *responseRecorder {
+		t.Helper()
+		keys := server.Keys()
+		var requestID string
+		for _, key := range keys {
+			if strings.HasPrefix(key, ApprovalKeyPrefix+":") {
+				requestID = strings.TrimPrefix(key, ApprovalKeyPrefix+":")
+			}
+		}
+		if requestID == "" {
+			t.Fatalf("Expected a pending request, got keys %v", keys)
+		}
+		recorder := newResponseRecorder(t)
+		payload := &BlockActionsPayload{ResponseURL: recorder.URL}
+		payload.User.ID = "U_APPROVER"
+		if err := service.handleApprovalAction(ctx, payload, &BlockAction{ActionID: actionID, Value: requestID}); err != nil {
+			t.Fatalf("handleApprovalAction() failed: %v", err)
+		}
+		return recorder
+	}
+
+	// A rejected request never counts
+	if response := submit("V1", "first"); response != nil {
+		t.Fatalf("Expected the request to be held, got %+v", response)
+	}
+	if server.Exists(userKey) {
+		t.Fatal("Expected a held request not to count yet")
+	}
+	decide(ActionRejectRepo)
+	if server.Exists(userKey) {
+		t.Fatal("Expected a rejected request not to count")
+	}
+
+	// An approved one does, and is then the user's only request for the day
+	submit("V2", "second")
+	decide(ActionApproveRepo)
+	if members, _ := server.ZMembers(userKey); len(members) != 1 || len(queue.Commands()) != 1 {
+		t.Fatalf("Expected the approved request to be queued and counted, got %v and %d commands", members, len(queue.Commands()))
+	}
+	if response := submit("V3", "third"); response == nil || !strings.Contains(response.Errors["repo-name"], "reached the limit") {
+		t.Fatalf("Expected the limit to be reported in the modal, got %+v", response)
+	}
+
+	// A request that was held before the limit was reached stays pending until it fits
+	config.RateLimitUser.Limit = 2
+	submit("V4", "fourth")
+	config.RateLimitUser.Limit = 1
+	recorder := decide(ActionApproveRepo)
+	if messages := recorder.Messages(); len(messages) != 1 || !strings.Contains(messages[0].Text, "<@U1> has reached the limit") {
+		t.Errorf("Expected the approver to be told about the limit, got %+v", messages)
+	}
+	if len(queue.Commands()) != 1 {
+		t.Errorf("Expected nothing more to be queued, got %d commands", len(queue.Commands()))
+	}
+	// It is still pending, so it can be rejected
+	decide(ActionRejectRepo)
+}
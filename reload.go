@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ConfigStore holds the running configuration, which is swapped on SIGHUP
+// Handlers read it once per message, so a reload never changes the configuration
+// part-way through handling one
+type ConfigStore struct {
+	current atomic.Pointer[Config]
+}
+
+// NewConfigStore creates a ConfigStore holding config
+func NewConfigStore(config *Config) *ConfigStore {
+	store := &ConfigStore{}
+	store.current.Store(config)
+	return store
+}
+
+// Get returns the current configuration
+func (s *ConfigStore) Get() *Config {
+	return s.current.Load()
+}
+
+// Set replaces the current configuration
+func (s *ConfigStore) Set(config *Config) {
+	s.current.Store(config)
+}
+
+// ConfigChange is a setting whose value differs between two configurations
+type ConfigChange struct {
+	Env string
+	Old string
+	New string
+	// Restart is set if the change only takes effect after a restart
+	Restart bool
+}
+
+// diffConfig returns every setting that differs between old and new, in settings order
+// Secret values are redacted
+func diffConfig(old, new *Config) []ConfigChange {
+	var changes []ConfigChange
+	for _, s := range settings {
+		oldValue, newValue := formatSetting(s.Field(old)), formatSetting(s.Field(new))
+		if oldValue == newValue {
+			continue
+		}
+		if s.Secret {
+			oldValue, newValue = redacted, redacted
+		}
+		changes = append(changes, ConfigChange{Env: s.Env, Old: oldValue, New: newValue, Restart: s.Restart})
+	}
+	return changes
+}
+
+// formatSetting renders the Config field that field points to as it would be written in the environment
+func formatSetting(field interface{}) string {
+	switch field := field.(type) {
+	case *string:
+		return *field
+	case *[]string:
+		return strings.Join(*field, ",")
+	case *map[string]string:
+		pairs := make([]string, 0, len(*field))
+		for key, value := range *field {
+			pairs = append(pairs, key+"="+value)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	case *time.Duration:
+		return formatTTL(*field)
+	case *int:
+		return strconv.Itoa(*field)
+	case **RateLimit:
+		if *field == nil {
+			return ""
+		}
+		return (*field).String()
+	default:
+		return fmt.Sprintf("%v", field)
+	}
+}
+
+// copySetting sets the Config field dst points to from the field src points to
+func copySetting(dst, src interface{}) {
+	switch dst := dst.(type) {
+	case *string:
+		*dst = *src.(*string)
+	case *[]string:
+		*dst = *src.(*[]string)
+	case *map[string]string:
+		*dst = *src.(*map[string]string)
+	case *time.Duration:
+		*dst = *src.(*time.Duration)
+	case *int:
+		*dst = *src.(*int)
+	case **RateLimit:
+		*dst = *src.(**RateLimit)
+	}
+}
+
+// reloadConfig loads the configuration again with load and compares it with current
+// Settings that need a restart keep their running values in the returned configuration,
+// so it always describes what the service is actually doing
+func reloadConfig(current *Config, load func() (*Config, error)) (*Config, []ConfigChange, error) {
+	next, err := load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := diffConfig(current, next)
+	for _, change := range changes {
+		if !change.Restart {
+			continue
+		}
+		for _, s := range settings {
+			if s.Env == change.Env {
+				copySetting(s.Field(next), s.Field(current))
+			}
+		}
+	}
+	return next, changes, nil
+}
+
+// applyReload reloads the configuration into configs, logs what changed and moves
+// subscriptions whose channels were renamed
+// On error the running configuration is left untouched
+func applyReload(ctx context.Context, logger *Logger, configs *ConfigStore, subs []*subscription, health *HealthChecker, router *CommandRouter) {
+	logger.Info("Received SIGHUP, reloading configuration")
+
+	next, changes, err := reloadConfig(configs.Get(), loadConfig)
+	if err != nil {
+		logger.Error("Failed to reload configuration, keeping the running configuration", "error", err)
+		return
+	}
+	if len(changes) == 0 {
+		logger.Info("Configuration reloaded, nothing changed")
+		return
+	}
+
+	for _, change := range changes {
+		if change.Restart {
+			logger.Warn("Configuration setting changed but needs a restart to take effect", "setting", change.Env, "old", change.Old, "new", change.New)
+			continue
+		}
+		logger.Info("Configuration setting changed", "setting", change.Env, "old", change.Old, "new", change.New)
+	}
+
+	configs.Set(next)
+	router.SetVerifier(NewPayloadVerifier(next.SlackVerificationToken, next.AllowedTeamIDs, next.AllowedAppIDs))
+
+	for _, sub := range subs {
+		if err := sub.sync(ctx, logger, health, next); err != nil {
+			logger.Error("Failed to move subscription, keeping the previous channel", "subscription", sub.name, "channel", sub.current, "error", err)
+		}
+	}
+	logger.Info("Configuration reloaded", "changes", len(changes))
+}
+
+// subscription is a Redis subscription whose channel comes from the configuration,
+// so it can follow a rename on reload; an empty channel means it is disabled
+type subscription struct {
+	name    string
+	channel func(config *Config) string
+	open    func(ctx context.Context, channel string) (Subscriber, error)
+
+	current string
+	sub     Subscriber
+}
+
+// Messages returns the current subscriber's message channel, or nil while disabled
+// A nil channel blocks forever, so a select simply never picks it
+func (s *subscription) Messages() <-chan *Message {
+	if s.sub == nil {
+		return nil
+	}
+	return s.sub.Messages()
+}
+
+// sync subscribes to the channel named in config if it is not the current one
+// The previous subscription is closed only once the new one is live, and is kept if subscribing fails
+func (s *subscription) sync(ctx context.Context, logger *Logger, health *HealthChecker, config *Config) error {
+	channel := s.channel(config)
+	if channel == s.current {
+		return nil
+	}
+
+	var sub Subscriber
+	if channel != "" {
+		logger.Info("Subscribing to Redis channel", "subscription", s.name, "channel", channel, "transport", config.Transport)
+		var err error
+		if sub, err = s.open(ctx, channel); err != nil {
+			return err
+		}
+		health.AddSubscriber(channel, sub)
+		logger.Info("Successfully subscribed to Redis channel", "subscription", s.name, "channel", channel)
+	}
+
+	if s.sub != nil {
+		logger.Info("Unsubscribing from Redis channel", "subscription", s.name, "channel", s.current)
+		health.RemoveSubscriber(s.current)
+		s.sub.Close()
+		go drainSubscriber(logger, s.current, s.sub)
+	}
+
+	s.current, s.sub = channel, sub
+	return nil
+}
+
+// Close closes the current subscriber, if any
+func (s *subscription) Close() {
+	if s.sub != nil {
+		s.sub.Close()
+	}
+}
+
+// drainSubscriber discards anything a closed subscriber was still delivering, so its goroutine can exit
+// Stream entries are left pending and will be reclaimed; Pub/Sub messages are lost
+func drainSubscriber(logger *Logger, channel string, sub Subscriber) {
+	for msg := range sub.Messages() {
+		logger.Warn("Dropped message received while unsubscribing", "channel", channel, "source", msg.Source)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// closableSubscriber is a Subscriber that records whether it was closed
+type closableSubscriber struct {
+	messages chan *Message
+	closed   bool
+}
+
+func newClosableSubscriber() *closableSubscriber {
+	return &closableSubscriber{messages: make(chan *Message)}
+}
+
+func (s *closableSubscriber) Messages() <-chan *Message { return s.messages }
+func (s *closableSubscriber) Subscribed() bool          { return !s.closed }
+func (s *closableSubscriber) Close() error {
+	if !s.closed {
+		s.closed = true
+		close(s.messages)
+	}
+	return nil
+}
+
+// TestReloadConfig tests that changes are reported and restart-only settings keep their running values
+func TestReloadConfig(t *testing.T) {
+	current := &Config{
+		RedisAddr:      "redis:6379",
+		RedisChannel:   "slack-commands",
+		GithubOrg:      "old-org",
+		GithubToken:    "ghp_old",
+		AllowedTeamIDs: []string{"T1"},
+		ApprovalTTL:    24 * time.Hour,
+	}
+	load := func() (*Config, error) {
+		return &Config{
+			RedisAddr:      "other-redis:6379",
+			RedisChannel:   "slack-commands",
+			GithubOrg:      "new-org",
+			GithubToken:    "ghp_new",
+			AllowedTeamIDs: []string{"T1", "T2"},
+			ApprovalTTL:    time.Hour,
+			RateLimitUser:  &RateLimit{Limit: 5, Window: 24 * time.Hour},
+		}, nil
+	}
+
+	next, changes, err := reloadConfig(current, load)
+	if err != nil {
+		t.Fatalf("reloadConfig() failed: %v", err)
+	}
+
+	want := []ConfigChange{
+		{Env: "REDIS_ADDR", Old: "redis:6379", New: "other-redis:6379", Restart: true},
+		{Env: "ALLOWED_TEAM_IDS", Old: "T1", New: "T1,T2"},
+		{Env: "APPROVAL_TTL", Old: "24h", New: "1h"},
+		{Env: "RATE_LIMIT_USER", Old: "", New: "5/24h"},
+		{Env: "GITHUB_ORG", Old: "old-org", New: "new-org"},
+		{Env: "GITHUB_TOKEN", Old: redacted, New: redacted, Restart: true},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("reloadConfig() changes =\n%+v\nwant\n%+v", changes, want)
+	}
+
+	if next.GithubOrg != "new-org" || next.ApprovalTTL != time.Hour {
+		t.Errorf("Expected reloadable settings to change, got GithubOrg %q and ApprovalTTL %s", next.GithubOrg, next.ApprovalTTL)
+	}
+	if next.RedisAddr != "redis:6379" || next.GithubToken != "ghp_old" {
+		t.Errorf("Expected restart-only settings to keep their running values, got RedisAddr %q and GithubToken %q", next.RedisAddr, next.GithubToken)
+	}
+}
+
+// TestReloadConfigError tests that an invalid configuration is not returned
+func TestReloadConfigError(t *testing.T) {
+	next, _, err := reloadConfig(&Config{}, func() (*Config, error) { return nil, errors.New("GITHUB_ORG must be set") })
+	if err == nil || next != nil {
+		t.Errorf("Expected an error and no configuration, got %+v, %v", next, err)
+	}
+}
+
+// TestSubscriptionSync tests that a subscription follows its channel and keeps the old one on failure
+func TestSubscriptionSync(t *testing.T) {
+	health := NewHealthChecker(nil, nil)
+	logger := NewLogger("error")
+	ctx := context.Background()
+
+	var opened []string
+	var openErr error
+	sub := &subscription{
+		name:    "slash_commands",
+		channel: func(c *Config) string { return c.RedisChannel },
+		open: func(ctx context.Context, channel string) (Subscriber, error) {
+			if openErr != nil {
+				return nil, openErr
+			}
+			opened = append(opened, channel)
+			return newClosableSubscriber(), nil
+		},
+	}
+
+	if err := sub.sync(ctx, logger, health, &Config{RedisChannel: "commands-a"}); err != nil {
+		t.Fatalf("sync() failed: %v", err)
+	}
+	first := sub.sub.(*closableSubscriber)
+
+	// An unchanged channel is left alone
+	if err := sub.sync(ctx, logger, health, &Config{RedisChannel: "commands-a"}); err != nil || len(opened) != 1 {
+		t.Fatalf("Expected no resubscribe, got opened %v, err %v", opened, err)
+	}
+
+	if err := sub.sync(ctx, logger, health, &Config{RedisChannel: "commands-b"}); err != nil {
+		t.Fatalf("sync() failed: %v", err)
+	}
+	if !first.closed || sub.current != "commands-b" || !reflect.DeepEqual(opened, []string{"commands-a", "commands-b"}) {
+		t.Errorf("Expected to move to commands-b and close the old subscriber, got current %q, opened %v", sub.current, opened)
+	}
+	checks := health.Readiness().Checks
+	if _, ok := checks["subscription:commands-a"]; ok {
+		t.Errorf("Expected the old subscription to leave the readiness check, got %+v", checks)
+	}
+	if _, ok := checks["subscription:commands-b"]; !ok {
+		t.Errorf("Expected the new subscription in the readiness check, got %+v", checks)
+	}
+
+	openErr = errors.New("redis unavailable")
+	second := sub.sub.(*closableSubscriber)
+	if err := sub.sync(ctx, logger, health, &Config{RedisChannel: "commands-c"}); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if second.closed || sub.current != "commands-b" {
+		t.Errorf("Expected to stay on commands-b, got current %q, closed %v", sub.current, second.closed)
+	}
+
+	// An empty channel disables the subscription
+	openErr = nil
+	if err := sub.sync(ctx, logger, health, &Config{}); err != nil {
+		t.Fatalf("sync() failed: %v", err)
+	}
+	if !second.closed || sub.Messages() != nil {
+		t.Error("Expected the subscription to be closed and disabled")
+	}
+}
+
+// TestCommandRouterSetVerifier tests that a reloaded allow-list applies to the next payload
+func TestCommandRouterSetVerifier(t *testing.T) {
+	router := NewCommandRouter(NewLogger("error"), &fakeViewResponder{}, NewPayloadVerifier("", []string{"T1"}, nil))
+
+	var handled []string
+	err := router.Register(&SlashCommand{
+		Name: "/alpha",
+		HandleCommand: func(ctx context.Context, cmd *SlashCommandPayload) {
+			handled = append(handled, cmd.TeamID)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register command: %v", err)
+	}
+
+	ctx := context.Background()
+	router.HandleMessage(ctx, `{"command":"/alpha","team_id":"T2"}`)
+	router.SetVerifier(NewPayloadVerifier("", []string{"T1", "T2"}, nil))
+	router.HandleMessage(ctx, `{"command":"/alpha","team_id":"T2"}`)
+
+	if !reflect.DeepEqual(handled, []string{"T2"}) {
+		t.Errorf("Expected only the payload after the reload to be handled, got %v", handled)
+	}
+}
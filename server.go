@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// httpShutdownTimeout bounds how long in-flight HTTP requests get to finish on shutdown
+const httpShutdownTimeout = 5 * time.Second
+
+// newHTTPHandler builds the routes served on HTTP_ADDR
+func newHTTPHandler(health *HealthChecker) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", health.handleReadyz)
+	return mux
+}
+
+// startHTTPServer serves handler on addr until ctx is cancelled
+func startHTTPServer(ctx context.Context, logger *Logger, addr string, handler http.Handler) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Info("Starting HTTP server", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("HTTP server failed", "addr", addr, "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to shut down HTTP server", "error", err)
+		}
+	}()
+}
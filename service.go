@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// ViewOpener opens Slack modals (satisfied by *slack.Client)
+type ViewOpener interface {
+	OpenViewContext(ctx context.Context, triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error)
+}
+
+// Queue hands Poppit commands over to be run
+type Queue interface {
+	Enqueue(ctx context.Context, cmd *PoppitCommand) error
+}
+
+// Notifier posts a message to a Slack channel
+type Notifier interface {
+	Notify(ctx context.Context, channel, text string) error
+}
+
+// RedisQueue pushes Poppit commands to REDIS_POPPIT_LIST
+type RedisQueue struct {
+	client  *redis.Client
+	configs *ConfigStore
+}
+
+// Enqueue pushes cmd to the Poppit list named in the current configuration
+func (q *RedisQueue) Enqueue(ctx context.Context, cmd *PoppitCommand) error {
+	payload, err := json.Marshal(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Poppit command: %w", err)
+	}
+	if err := q.client.RPush(ctx, q.configs.Get().RedisPoppitList, string(payload)).Err(); err != nil {
+		pushErrorsTotal.WithLabelValues(PushTargetPoppit).Inc()
+		return fmt.Errorf("failed to push to Poppit list: %w", err)
+	}
+	return nil
+}
+
+// SlackLinerNotifier pushes messages to REDIS_SLACKLINER_LIST for SlackLiner to post
+// Messages are deleted by SlackLiner after 7 days
+type SlackLinerNotifier struct {
+	client  *redis.Client
+	configs *ConfigStore
+}
+
+// Notify pushes a SlackLiner message for channel
+func (n *SlackLinerNotifier) Notify(ctx context.Context, channel, text string) error {
+	payload, err := json.Marshal(SlackLinerMessage{Channel: channel, Text: text, TTL: SevenDaysTTL})
+	if err != nil {
+		return fmt.Errorf("failed to marshal SlackLiner message: %w", err)
+	}
+	if err := n.client.RPush(ctx, n.configs.Get().RedisSlackLinerList, string(payload)).Err(); err != nil {
+		pushErrorsTotal.WithLabelValues(PushTargetSlackLiner).Inc()
+		return fmt.Errorf("failed to push to SlackLiner list: %w", err)
+	}
+	return nil
+}
+
+// Service handles slash commands, view submissions, block actions and Poppit results
+// Redis holds the service's own state (deduplication, rate limits, approvals and pending
+// requests); everything it sends elsewhere goes through the interfaces, so tests can fake them
+type Service struct {
+	Logger      *Logger
+	Configs     *ConfigStore
+	Redis       *redis.Client
+	Views       ViewOpener
+	Poster      MessagePoster
+	Queue       Queue
+	Notifier    Notifier
+	RepoChecker RepoChecker
+	Authorizer  Authorizer
+	Catalog     *TemplateCatalog
+	Health      *HealthChecker
+}
+
+// Run subscribes to the configured channels and handles messages until ctx is cancelled
+// SIGHUP reloads the configuration between messages
+func (s *Service) Run(ctx context.Context) error {
+	config := s.Configs.Get()
+
+	router, err := s.newRouter(config)
+	if err != nil {
+		return err
+	}
+
+	commandSub, viewSubmissionSub, poppitOutputSub, blockActionsSub := s.subscriptions()
+	subs := []*subscription{commandSub, viewSubmissionSub, poppitOutputSub, blockActionsSub}
+	for _, sub := range subs {
+		if err := sub.sync(ctx, s.Logger, s.Health, config); err != nil {
+			return fmt.Errorf("failed to subscribe %s to %s: %w", sub.name, sub.channel(config), err)
+		}
+		defer sub.Close()
+	}
+
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	defer signal.Stop(hupChan)
+
+	// Process messages from all channels
+	for {
+		select {
+		case <-ctx.Done():
+			s.Logger.Info("Shutting down...")
+			return nil
+		case <-hupChan:
+			applyReload(ctx, s.Logger, s.Configs, subs, s.Health, router)
+		case msg := <-commandSub.Messages():
+			if msg == nil {
+				continue
+			}
+			err := router.HandleMessage(ctx, msg.Payload)
+			finishMessage(ctx, s.Logger, s.Redis, s.Configs.Get(), msg, err)
+		case msg := <-viewSubmissionSub.Messages():
+			if msg == nil {
+				continue
+			}
+			err := router.HandleViewSubmission(ctx, msg.Payload)
+			finishMessage(ctx, s.Logger, s.Redis, s.Configs.Get(), msg, err)
+		case msg := <-blockActionsSub.Messages():
+			if msg == nil {
+				continue
+			}
+			err := router.HandleBlockActions(ctx, msg.Payload)
+			finishMessage(ctx, s.Logger, s.Redis, s.Configs.Get(), msg, err)
+		case msg := <-poppitOutputSub.Messages():
+			if msg == nil {
+				continue
+			}
+			s.handlePoppitOutput(ctx, msg.Payload)
+		}
+	}
+}
+
+// newRouter builds the command router and registers the supported commands
+func (s *Service) newRouter(config *Config) (*CommandRouter, error) {
+	viewResponder := &RedisViewResponder{client: s.Redis, prefix: config.RedisViewResponsePrefix}
+	verifier := NewPayloadVerifier(config.SlackVerificationToken, config.AllowedTeamIDs, config.AllowedAppIDs)
+	if config.SlackVerificationToken == "" && len(config.AllowedTeamIDs) == 0 && len(config.AllowedAppIDs) == 0 {
+		s.Logger.Warn("No payload verification configured, accepting payloads from any workspace or app")
+	}
+
+	router := NewCommandRouter(s.Logger, viewResponder, verifier)
+	if err := registerCommands(router, s); err != nil {
+		return nil, fmt.Errorf("failed to register commands: %w", err)
+	}
+	return router, nil
+}
+
+// subscriptions returns the service's subscriptions, which follow their channel names in the
+// configuration so a reload can move them
+func (s *Service) subscriptions() (commandSub, viewSubmissionSub, poppitOutputSub, blockActionsSub *subscription) {
+	subscribeConfigured := func(ctx context.Context, channel string) (Subscriber, error) {
+		return newSubscriber(ctx, s.Logger, s.Redis, s.Configs.Get(), channel)
+	}
+	commandSub = &subscription{
+		name:    "slash_commands",
+		channel: func(c *Config) string { return c.RedisChannel },
+		open:    subscribeConfigured,
+	}
+	viewSubmissionSub = &subscription{
+		name:    "view_submissions",
+		channel: func(c *Config) string { return c.RedisViewSubmissionChannel },
+		open:    subscribeConfigured,
+	}
+	// Poppit publishes its results with Pub/Sub regardless of the configured transport
+	poppitOutputSub = &subscription{
+		name:    "poppit_output",
+		channel: func(c *Config) string { return c.RedisPoppitOutputChannel },
+		open: func(ctx context.Context, channel string) (Subscriber, error) {
+			return NewPubSubSubscriber(ctx, s.Redis, channel, pubSubPingInterval)
+		},
+	}
+	// Approve/Reject button clicks arrive as block actions; without approvals there is nothing to click
+	blockActionsSub = &subscription{
+		name: "block_actions",
+		channel: func(c *Config) string {
+			if c.ApproversChannel == "" {
+				return ""
+			}
+			return c.RedisBlockActionsChannel
+		},
+		open: subscribeConfigured,
+	}
+	return commandSub, viewSubmissionSub, poppitOutputSub, blockActionsSub
+}
+
+// notify sends text to channel, logging rather than returning failures
+// Notifications report on work that has already happened, so failing to send one is not retried
+func (s *Service) notify(ctx context.Context, channel, text string) bool {
+	if err := s.Notifier.Notify(ctx, channel, text); err != nil {
+		s.Logger.Error("Failed to send notification", "channel", channel, "error", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// fakeViewOpener records the modals it is asked to open
+type fakeViewOpener struct {
+	mu    sync.Mutex
+	views []slack.ModalViewRequest
+	err   error
+}
+
+func (f *fakeViewOpener) OpenViewContext(ctx context.Context, triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.views = append(f.views, view)
+	return &slack.ViewResponse{}, nil
+}
+
+func (f *fakeViewOpener) Views() []slack.ModalViewRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]slack.ModalViewRequest(nil), f.views...)
+}
+
+// fakeMessagePoster records the channels it posts to
+type fakeMessagePoster struct {
+	mu       sync.Mutex
+	channels []string
+	err      error
+}
+
+func (f *fakeMessagePoster) PostMessageContext(ctx context.Context, channelID string, options ...slack.MsgOption) (string, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return "", "", f.err
+	}
+	f.channels = append(f.channels, channelID)
+	return channelID, "1700000000.000100", nil
+}
+
+// fakeQueue records the Poppit commands it is given
+type fakeQueue struct {
+	mu       sync.Mutex
+	commands []PoppitCommand
+	err      error
+}
+
+func (f *fakeQueue) Enqueue(ctx context.Context, cmd *PoppitCommand) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.commands = append(f.commands, *cmd)
+	return nil
+}
+
+func (f *fakeQueue) Commands() []PoppitCommand {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]PoppitCommand(nil), f.commands...)
+}
+
+// fakeNotifier records the messages it is asked to send
+type fakeNotifier struct {
+	mu       sync.Mutex
+	messages []SlackLinerMessage
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, channel, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, SlackLinerMessage{Channel: channel, Text: text})
+	return nil
+}
+
+func (f *fakeNotifier) Messages() []SlackLinerMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]SlackLinerMessage(nil), f.messages...)
+}
+
+// responseRecorder is a stand-in for Slack's response_url that records the messages posted to it
+type responseRecorder struct {
+	URL      string
+	mu       sync.Mutex
+	messages []slack.WebhookMessage
+}
+
+// newResponseRecorder starts a response_url server that is closed when the test ends
+func newResponseRecorder(t *testing.T) *responseRecorder {
+	t.Helper()
+	recorder := &responseRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg slack.WebhookMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("Failed to decode response_url message: %v", err)
+		}
+		recorder.mu.Lock()
+		recorder.messages = append(recorder.messages, msg)
+		recorder.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	recorder.URL = server.URL
+	return recorder
+}
+
+func (r *responseRecorder) Messages() []slack.WebhookMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]slack.WebhookMessage(nil), r.messages...)
+}
+
+// newTestService returns a Service backed by miniredis and in-memory fakes, allowing everyone
+// Tests replace the fields they care about
+func newTestService(t *testing.T, config *Config) (*Service, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	return &Service{
+		Logger:      NewLogger("error"),
+		Configs:     NewConfigStore(config),
+		Redis:       redisClient,
+		Views:       &fakeViewOpener{},
+		Poster:      &fakeMessagePoster{},
+		Queue:       &fakeQueue{},
+		Notifier:    &fakeNotifier{},
+		RepoChecker: &fakeRepoChecker{},
+		Authorizer:  &fakeAuthorizer{},
+		Health:      NewHealthChecker(nil, nil),
+	}, server
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestServiceRun tests a repository request end to end: slash command, modal submission and Poppit result
+func TestServiceRun(t *testing.T) {
+	config := &Config{
+		Transport:                  TransportPubSub,
+		RedisChannel:               "slack-commands",
+		RedisViewSubmissionChannel: "view-submissions",
+		RedisViewResponsePrefix:    "view-response",
+		RedisPoppitOutputChannel:   "poppit-output",
+		RedisDeadLetterList:        "dead-letters",
+		GithubOrg:                  "my-org",
+		SlackChannelNewRepo:        "#new-repo",
+	}
+	service, server := newTestService(t, config)
+	views := service.Views.(*fakeViewOpener)
+	queue := service.Queue.(*fakeQueue)
+	notifier := service.Notifier.(*fakeNotifier)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- service.Run(ctx) }()
+
+	waitFor(t, "subscriptions", func() bool {
+		subscribers := server.PubSubNumSub(config.RedisChannel, config.RedisViewSubmissionChannel, config.RedisPoppitOutputChannel)
+		for _, n := range subscribers {
+			if n != 1 {
+				return false
+			}
+		}
+		return true
+	})
+
+	// The slash command opens the modal with the suggested name
+	server.Publish(config.RedisChannel, `{"command":"/new-repo","text":"My Tool","user_id":"U1","trigger_id":"1.2.abc","channel_id":"C1"}`)
+	waitFor(t, "the modal", func() bool { return len(views.Views()) == 1 })
+	if views.Views()[0].CallbackID != NewRepoModalCallbackID {
+		t.Errorf("Unexpected modal: %+v", views.Views()[0])
+	}
+
+	// Submitting it queues the Poppit command, confirms and closes the modal
+	server.Publish(config.RedisViewSubmissionChannel, `{"type":"view_submission","user":{"id":"U1"},"view":{"id":"V1","callback_id":"create_github_repo_modal","state":{"values":{
+		"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"My-Tool"}}}}}}`)
+	waitFor(t, "the Poppit command", func() bool { return len(queue.Commands()) == 1 })
+	cmd := queue.Commands()[0]
+	if cmd.Repo != "my-org/My-Tool" || cmd.Metadata == nil || cmd.Metadata.UserID != "U1" {
+		t.Errorf("Unexpected Poppit command: %+v", cmd)
+	}
+	waitFor(t, "the view response", func() bool { return server.Exists("view-response:V1") })
+	if response, _ := server.Lpop("view-response:V1"); response != "{}" {
+		t.Errorf("Expected the modal to close, got %s", response)
+	}
+	if messages := notifier.Messages(); len(messages) != 1 || !strings.Contains(messages[0].Text, "New repository creation initiated") {
+		t.Errorf("Expected a confirmation, got %+v", messages)
+	}
+
+	// The result of the last command reports the outcome
+	output, _ := json.Marshal(PoppitOutput{
+		CorrelationID: cmd.CorrelationID,
+		Type:          PoppitNewRepoType,
+		Repo:          cmd.Repo,
+		Command:       cmd.Commands[len(cmd.Commands)-1],
+		Status:        "success",
+	})
+	server.Publish(config.RedisPoppitOutputChannel, string(output))
+	waitFor(t, "the outcome", func() bool { return len(notifier.Messages()) == 2 })
+	if message := notifier.Messages()[1]; message.Channel != "#new-repo" || !strings.Contains(message.Text, "my-org/My-Tool") {
+		t.Errorf("Unexpected outcome message: %+v", message)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after the context was cancelled")
+	}
+}
+
+// TestServiceRunQueueFailure tests that a submission that cannot be queued is dead-lettered and can be retried
+func TestServiceRunQueueFailure(t *testing.T) {
+	config := &Config{
+		Transport:                  TransportPubSub,
+		RedisViewSubmissionChannel: "view-submissions",
+		RedisDeadLetterList:        "dead-letters",
+		GithubOrg:                  "my-org",
+	}
+	service, server := newTestService(t, config)
+	queue := service.Queue.(*fakeQueue)
+	queue.err = errors.New("connection refused")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- service.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	waitFor(t, "the subscription", func() bool {
+		return server.PubSubNumSub(config.RedisViewSubmissionChannel)[config.RedisViewSubmissionChannel] == 1
+	})
+
+	server.Publish(config.RedisViewSubmissionChannel, `{"type":"view_submission","user":{"id":"U1"},"view":{"id":"V1","callback_id":"create_github_repo_modal","state":{"values":{
+		"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"tool"}}}}}}`)
+	waitFor(t, "the dead letter", func() bool { return server.Exists("dead-letters") })
+
+	// The claim was released, so the same submission is not treated as a duplicate
+	submission := &ViewSubmissionPayload{}
+	submission.View.ID = "V1"
+	if server.Exists(submissionKey(submission, "my-org/tool")) {
+		t.Error("Expected the submission claim to be released")
+	}
+}
+
+// TestHandleNewRepoCommand tests that permitted users get the modal and others an ephemeral denial
+func TestHandleNewRepoCommand(t *testing.T) {
+	recorder := newResponseRecorder(t)
+	service, _ := newTestService(t, &Config{GithubOrg: "my-org"})
+	service.Authorizer = &fakeAuthorizer{allowed: map[string]bool{"U1": true}}
+	views := service.Views.(*fakeViewOpener)
+
+	service.handleNewRepoCommand(context.Background(), &SlashCommandPayload{UserID: "U1", TriggerID: "1.2.abc", Text: "tool", ResponseURL: recorder.URL})
+	if len(views.Views()) != 1 || len(recorder.Messages()) != 0 {
+		t.Fatalf("Expected a modal and no response, got %d modals and %+v", len(views.Views()), recorder.Messages())
+	}
+
+	service.handleNewRepoCommand(context.Background(), &SlashCommandPayload{UserID: "U2", TriggerID: "1.2.def", ResponseURL: recorder.URL})
+	if len(views.Views()) != 1 {
+		t.Errorf("Expected no modal for a denied user, got %d", len(views.Views()))
+	}
+	if responses := recorder.Messages(); len(responses) != 1 || !strings.Contains(responses[0].Text, "not allowed") || responses[0].ResponseType != slack.ResponseTypeEphemeral {
+		t.Errorf("Expected an ephemeral denial, got %+v", recorder.Messages())
+	}
+}
+
+// TestRedisQueueAndNotifier tests that the Redis implementations push to the configured lists
+func TestRedisQueueAndNotifier(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+	configs := NewConfigStore(&Config{RedisPoppitList: "poppit", RedisSlackLinerList: "slackliner"})
+	ctx := context.Background()
+
+	queue := &RedisQueue{client: redisClient, configs: configs}
+	if err := queue.Enqueue(ctx, &PoppitCommand{Repo: "my-org/tool", Commands: []string{"gh repo create my-org/tool --public"}}); err != nil {
+		t.Fatalf("Enqueue() failed: %v", err)
+	}
+	queued, err := server.Lpop("poppit")
+	if err != nil {
+		t.Fatalf("Expected a Poppit command: %v", err)
+	}
+	var cmd PoppitCommand
+	if err := json.Unmarshal([]byte(queued), &cmd); err != nil || cmd.Repo != "my-org/tool" {
+		t.Errorf("Unexpected Poppit command %s: %v", queued, err)
+	}
+
+	notifier := &SlackLinerNotifier{client: redisClient, configs: configs}
+	if err := notifier.Notify(ctx, "#new-repo", "hello"); err != nil {
+		t.Fatalf("Notify() failed: %v", err)
+	}
+	pushed, err := server.Lpop("slackliner")
+	if err != nil {
+		t.Fatalf("Expected a SlackLiner message: %v", err)
+	}
+	var message SlackLinerMessage
+	if err := json.Unmarshal([]byte(pushed), &message); err != nil || message != (SlackLinerMessage{Channel: "#new-repo", Text: "hello", TTL: SevenDaysTTL}) {
+		t.Errorf("Unexpected SlackLiner message %s: %v", pushed, err)
+	}
+
+	// A list that has moved in a reload is used straight away
+	configs.Set(&Config{RedisPoppitList: "poppit-v2"})
+	if err := queue.Enqueue(ctx, &PoppitCommand{Repo: "my-org/other"}); err != nil {
+		t.Fatalf("Enqueue() failed: %v", err)
+	}
+	if !server.Exists("poppit-v2") {
+		t.Error("Expected the command on the reloaded list")
+	}
+
+	server.Close()
+	if err := queue.Enqueue(ctx, &PoppitCommand{Repo: "my-org/tool"}); err == nil {
+		t.Error("Expected an error when Redis is unavailable")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import "strings"
+
+// ShellCommand is a command line modelled as an argument vector
+// Arguments are kept verbatim and only quoted when the command is rendered for Poppit
+type ShellCommand struct {
+	Args []string
+}
+
+// NewShellCommand starts a command from its name and leading arguments, e.g. NewShellCommand("gh", "repo", "create")
+func NewShellCommand(args ...string) *ShellCommand {
+	return &ShellCommand{Args: append([]string(nil), args...)}
+}
+
+// Arg appends arguments to the command
+func (c *ShellCommand) Arg(args ...string) *ShellCommand {
+	c.Args = append(c.Args, args...)
+	return c
+}
+
+// Flag appends a flag and its value, e.g. Flag("--repo", "org/name")
+func (c *ShellCommand) Flag(name, value string) *ShellCommand {
+	return c.Arg(name, value)
+}
+
+// String renders the command for a POSIX shell, the form PoppitCommand.Commands expects
+// Each argument is quoted if it contains anything the shell would interpret
+func (c *ShellCommand) String() string {
+	words := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		words[i] = shellWord(arg)
+	}
+	return strings.Join(words, " ")
+}
+
+// renderCommands renders each command with String
+func renderCommands(commands ...*ShellCommand) []string {
+	rendered := make([]string, len(commands))
+	for i, command := range commands {
+		rendered[i] = command.String()
+	}
+	return rendered
+}
+
+// shellWord returns arg unchanged if the shell would read it back as-is, otherwise quoted
+func shellWord(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	for _, r := range arg {
+		if !isShellSafe(r) {
+			return shellQuote(arg)
+		}
+	}
+	return arg
+}
+
+// isShellSafe reports whether r has no special meaning to the shell anywhere in a word
+// '=' is only special in a leading assignment, which an argument vector never starts with
+func isShellSafe(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("@%+=:,./_-", r)
+}
+
+// shellQuote wraps s in single quotes so it is passed to the shell verbatim
+// Any single quotes inside s are closed, escaped and reopened
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, `'`, `'\''`) + "'"
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// hostileArgs are values a user could type into the modal to escape the quoting
+var hostileArgs = []string{
+	"",
+	"it's",
+	"$(echo injected)",
+	"`echo injected`",
+	"a; echo injected",
+	"' ; echo injected; '",
+	`"double" and \back\slash`,
+	"first line\nsecond line",
+	"$HOME ~ * ? [a] #comment",
+	"tab\there",
+	"a && b || c | d > e < f",
+	"ünïcödé ✅",
+}
+
+// TestShellQuote tests that values are wrapped in single quotes with embedded quotes escaped
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "''"},
+		{"simple", "'simple'"},
+		{"with spaces", "'with spaces'"},
+		{"it's", `'it'\''s'`},
+		{"$(rm -rf /)", "'$(rm -rf /)'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := shellQuote(tt.input); got != tt.want {
+				t.Errorf("shellQuote(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestShellCommandString tests that only arguments that need it are quoted
+func TestShellCommandString(t *testing.T) {
+	tests := []struct {
+		name    string
+		command *ShellCommand
+		want    string
+	}{
+		{"Plain", NewShellCommand("gh", "repo", "clone", "my-org/my_repo.go"), "gh repo clone my-org/my_repo.go"},
+		{"Flags", NewShellCommand("gh", "api").Flag("--method", "PUT").Flag("-f", "permission=admin"), "gh api --method PUT -f permission=admin"},
+		{"Assignee", NewShellCommand("gh", "issue", "create").Flag("--assignee", "@copilot"), "gh issue create --assignee @copilot"},
+		{"Spaces", NewShellCommand("gh").Flag("--description", "A service"), "gh --description 'A service'"},
+		{"Empty", NewShellCommand("gh").Flag("--description", ""), "gh --description ''"},
+		{"Quote", NewShellCommand("gh").Flag("--description", "it's"), `gh --description 'it'\''s'`},
+		{"Substitution", NewShellCommand("gh").Flag("--body", "$(rm -rf /)"), "gh --body '$(rm -rf /)'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.command.String(); got != tt.want {
+				t.Errorf("String() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestShellCommandThroughShell tests that a real shell passes every argument through unchanged
+func TestShellCommandThroughShell(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh on PATH")
+	}
+
+	// printf prints each argument followed by a NUL, so the arguments the shell saw can be split back out
+	command := NewShellCommand("printf", `%s\0`).Arg(hostileArgs...)
+	output, err := exec.Command(sh, "-c", command.String()).Output()
+	if err != nil {
+		t.Fatalf("sh -c %s failed: %v", command, err)
+	}
+
+	got := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	if !reflect.DeepEqual(got, hostileArgs) {
+		t.Errorf("The shell saw different arguments:\n got: %q\nwant: %q", got, hostileArgs)
+	}
+}
+
+// FuzzShellQuote checks that any quoted string is read back by the shell as the original, in one word
+func FuzzShellQuote(f *testing.F) {
+	for _, arg := range hostileArgs {
+		f.Add(arg)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if strings.ContainsRune(s, 0) {
+			t.Skip()
+		}
+		for _, quoted := range []string{shellQuote(s), shellWord(s)} {
+			words, err := splitShellWords(quoted)
+			if err != nil {
+				t.Fatalf("Quoted %q as %s, which does not parse: %v", s, quoted, err)
+			}
+			if !reflect.DeepEqual(words, []string{s}) {
+				t.Fatalf("Quoted %q as %s, which splits into %q", s, quoted, words)
+			}
+		}
+	})
+}
+
+// FuzzShellCommand checks that any argument survives rendering and POSIX word splitting unchanged
+func FuzzShellCommand(f *testing.F) {
+	for _, arg := range hostileArgs {
+		f.Add(arg, arg)
+	}
+
+	f.Fuzz(func(t *testing.T, description, prompt string) {
+		// The shell cannot pass NUL bytes in arguments at all
+		if strings.ContainsRune(description, 0) || strings.ContainsRune(prompt, 0) {
+			t.Skip()
+		}
+
+		commands := []*ShellCommand{
+			buildRepoCreateCommand("my-org/my-repo", description, DefaultVisibility, DefaultGitignore, DefaultLicense),
+		}
+		commands = append(commands, buildCopilotIssueCommands("my-org/my-repo", prompt)...)
+		for _, command := range commands {
+			words, err := splitShellWords(command.String())
+			if err != nil {
+				t.Fatalf("Rendered command %q does not parse: %v", command, err)
+			}
+			if !reflect.DeepEqual(words, command.Args) {
+				t.Fatalf("Rendered command %q splits into\n%q\nwant\n%q", command, words, command.Args)
+			}
+		}
+	})
+}
+
+// splitShellWords splits a command line into words the way a POSIX shell does
+// Anything that would make the shell expand, redirect or chain commands is an error,
+// since a safely rendered command never leaves it unquoted
+func splitShellWords(line string) ([]string, error) {
+	var words []string
+	var word bytes.Buffer
+	inWord := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote at %d", i)
+			}
+			word.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '\\':
+			if i+1 >= len(line) {
+				return nil, fmt.Errorf("trailing backslash")
+			}
+			i++
+			// A backslash-newline is a line continuation and disappears
+			if line[i] != '\n' {
+				word.WriteByte(line[i])
+				inWord = true
+			}
+		case strings.IndexByte("\"|&;<>()$`*?[#~", c) >= 0:
+			return nil, fmt.Errorf("unquoted %q at %d", c, i)
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// BlankTemplate is the template option value for a blank repository
+	BlankTemplate = "blank"
+	// MaxTemplates is the most templates a static_select can offer, with one option for a blank repository
+	MaxTemplates = 99
+)
+
+var (
+	// templateNamePattern restricts template names, which are used as option values
+	templateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	// templateRepoPattern matches owner/name, which ends up in a shell command
+	templateRepoPattern = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+)
+
+// RepoTemplate is a template repository new repositories can be created from
+// Commands run after the repository is created, instead of the clone and gh vibe init
+// used for a blank repository; {repo}, {org} and {name} are replaced with the new repository
+type RepoTemplate struct {
+	Name     string   `yaml:"name"`
+	Label    string   `yaml:"label"`
+	Repo     string   `yaml:"repo"`
+	Commands []string `yaml:"commands"`
+}
+
+// TemplateFile is the contents of TEMPLATES_FILE
+type TemplateFile struct {
+	Templates []RepoTemplate `yaml:"templates"`
+}
+
+// loadTemplates reads, parses and validates the catalogue at path
+// Every problem is reported at once
+func loadTemplates(path string) ([]RepoTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var file TemplateFile
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse templates file %s: %w", path, err)
+	}
+
+	var errs []error
+	if len(file.Templates) > MaxTemplates {
+		errs = append(errs, fmt.Errorf("at most %d templates can be offered, got %d", MaxTemplates, len(file.Templates)))
+	}
+	seen := make(map[string]bool)
+	for i := range file.Templates {
+		template := &file.Templates[i]
+		if !templateNamePattern.MatchString(template.Name) || template.Name == BlankTemplate {
+			errs = append(errs, fmt.Errorf("template %d: name %q must be lower case letters, numbers and hyphens, and not %q", i+1, template.Name, BlankTemplate))
+		}
+		if seen[template.Name] {
+			errs = append(errs, fmt.Errorf("template %s: name is used more than once", template.Name))
+		}
+		seen[template.Name] = true
+		if !templateRepoPattern.MatchString(template.Repo) {
+			errs = append(errs, fmt.Errorf("template %s: repo %q must be owner/name", template.Name, template.Repo))
+		}
+		for _, command := range template.Commands {
+			if strings.TrimSpace(command) == "" {
+				errs = append(errs, fmt.Errorf("template %s: commands must not be empty", template.Name))
+			}
+		}
+		if template.Label == "" {
+			template.Label = template.Name
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid templates file %s: %w", path, err)
+	}
+	return file.Templates, nil
+}
+
+// TemplateCatalog serves the templates in TEMPLATES_FILE
+// The file is reloaded when its modification time changes; if the new version cannot
+// be loaded the previous catalogue stays in force
+type TemplateCatalog struct {
+	file *reloadingFile[[]RepoTemplate]
+}
+
+// NewTemplateCatalog loads the catalogue at path; an empty path offers no templates
+func NewTemplateCatalog(logger *Logger, path string) (*TemplateCatalog, error) {
+	if path == "" {
+		return &TemplateCatalog{}, nil
+	}
+	file, err := newReloadingFile(logger, "templates", path, loadTemplates)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateCatalog{file: file}, nil
+}
+
+// Templates reloads the catalogue if it has changed and returns the templates in force
+// A nil catalogue has no templates
+func (c *TemplateCatalog) Templates() []RepoTemplate {
+	if c == nil || c.file == nil {
+		return nil
+	}
+	return c.file.Get()
+}
+
+// templateOptions returns the template select options, starting with a blank repository
+func templateOptions(templates []RepoTemplate) []selectOption {
+	options := []selectOption{{BlankTemplate, "Blank repository"}}
+	for _, template := range templates {
+		options = append(options, selectOption{template.Name, template.Label})
+	}
+	return options
+}
+
+// findTemplate returns the template called name, or nil
+func findTemplate(templates []RepoTemplate, name string) *RepoTemplate {
+	for i := range templates {
+		if templates[i].Name == name {
+			return &templates[i]
+		}
+	}
+	return nil
+}
+
+// buildTemplateRepoCreateCommand builds the gh repo create command for a repository created from template
+// gh does not allow --add-readme, --gitignore or --license with --template
+func buildTemplateRepoCreateCommand(repoFullName, repoDesc, visibility string, template *RepoTemplate) *ShellCommand {
+	ghRepoCreateCmd := NewShellCommand("gh", "repo", "create", repoFullName, "--"+visibility).Flag("--template", template.Repo)
+	if repoDesc != "" {
+		ghRepoCreateCmd.Flag("--description", repoDesc)
+	}
+	return ghRepoCreateCmd
+}
+
+// templateCommands returns the template's follow-up commands for repoFullName
+func templateCommands(template *RepoTemplate, repoFullName string) []string {
+	org, name, _ := strings.Cut(repoFullName, "/")
+	replacer := strings.NewReplacer("{repo}", repoFullName, "{org}", org, "{name}", name)
+
+	commands := make([]string, 0, len(template.Commands))
+	for _, command := range template.Commands {
+		commands = append(commands, replacer.Replace(command))
+	}
+	return commands
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTemplates = `
+templates:
+  - name: go-service
+    label: Go service
+    repo: my-org/go-service-template
+    commands:
+      - gh repo clone {repo}
+      - gh vibe init {repo} --name {name} --owner {org}
+  - name: ts-lib
+    repo: my-org/ts-lib-template
+`
+
+// TestLoadTemplates tests parsing, defaults and validation of the catalogue
+func TestLoadTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.yaml")
+	writePolicy(t, path, testTemplates, time.Now())
+
+	templates, err := loadTemplates(path)
+	if err != nil {
+		t.Fatalf("loadTemplates() failed: %v", err)
+	}
+	if len(templates) != 2 || templates[0].Label != "Go service" || templates[1].Label != "ts-lib" {
+		t.Errorf("Unexpected templates: %+v", templates)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"UnknownField", "templates:\n  - name: a\n    repo: o/a\n    command: x\n", "field command not found"},
+		{"BadName", "templates:\n  - name: Go Service\n    repo: o/a\n", "must be lower case"},
+		{"ReservedName", "templates:\n  - name: blank\n    repo: o/a\n", "must be lower case"},
+		{"Duplicate", "templates:\n  - name: a\n    repo: o/a\n  - name: a\n    repo: o/b\n", "used more than once"},
+		{"BadRepo", "templates:\n  - name: a\n    repo: 'o/a; rm -rf /'\n", "must be owner/name"},
+		{"EmptyCommand", "templates:\n  - name: a\n    repo: o/a\n    commands: ['']\n", "must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writePolicy(t, path, tt.content, time.Now())
+			if _, err := loadTemplates(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// TestTemplateCatalogReload tests that a changed catalogue is picked up and a broken one ignored
+func TestTemplateCatalogReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.yaml")
+	modTime := time.Now().Add(-time.Hour)
+	writePolicy(t, path, testTemplates, modTime)
+
+	catalog, err := NewTemplateCatalog(NewLogger("error"), path)
+	if err != nil {
+		t.Fatalf("NewTemplateCatalog() failed: %v", err)
+	}
+	if got := len(catalog.Templates()); got != 2 {
+		t.Fatalf("Expected 2 templates, got %d", got)
+	}
+
+	modTime = modTime.Add(time.Minute)
+	writePolicy(t, path, testTemplates+"  - name: python-cli\n    repo: my-org/python-cli-template\n", modTime)
+	if got := len(catalog.Templates()); got != 3 {
+		t.Errorf("Expected 3 templates after the reload, got %d", got)
+	}
+
+	modTime = modTime.Add(time.Minute)
+	writePolicy(t, path, "templates: [", modTime)
+	if got := len(catalog.Templates()); got != 3 {
+		t.Errorf("Expected the previous templates to stay in force, got %d", got)
+	}
+
+	var none *TemplateCatalog
+	if templates := none.Templates(); templates != nil {
+		t.Errorf("Expected no templates from a nil catalogue, got %+v", templates)
+	}
+	if _, err := NewTemplateCatalog(NewLogger("error"), filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing templates file")
+	}
+}
+
+// TestHandleViewSubmissionTemplate tests the commands queued for a repository created from a template
+func TestHandleViewSubmissionTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.yaml")
+	writePolicy(t, path, testTemplates, time.Now())
+	catalog, err := NewTemplateCatalog(NewLogger("error"), path)
+	if err != nil {
+		t.Fatalf("NewTemplateCatalog() failed: %v", err)
+	}
+
+	var submission ViewSubmissionPayload
+	payload := `{"type":"view_submission","user":{"id":"U1"},"view":{"id":"V1","callback_id":"create_github_repo_modal","state":{"values":{
+		"repo-name":{"repo_name_input":{"type":"plain_text_input","value":"svc"}},
+		"repo-description":{"repo_desc_input":{"type":"plain_text_input","value":"A service"}},
+		"repo-template":{"repo_template_select":{"type":"static_select","selected_option":{"value":"go-service"}}}}}}}`
+	if err := json.Unmarshal([]byte(payload), &submission); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+
+	service, _ := newTestService(t, &Config{GithubOrg: "my-org"})
+	service.Catalog = catalog
+	queue := service.Queue.(*fakeQueue)
+	response, err := service.handleViewSubmission(context.Background(), &submission)
+	if err != nil || response != nil {
+		t.Fatalf("Expected the request to be queued, got %+v, %v", response, err)
+	}
+
+	if len(queue.Commands()) != 1 {
+		t.Fatalf("Expected a Poppit command, got %d", len(queue.Commands()))
+	}
+	cmd := queue.Commands()[0]
+	want := []string{
+		"gh repo create my-org/svc --public --template my-org/go-service-template --description 'A service'",
+		"gh repo clone my-org/svc",
+		"gh vibe init my-org/svc --name svc --owner my-org",
+	}
+	if !reflect.DeepEqual(cmd.Commands, want) {
+		t.Errorf("Unexpected commands:\n%q\nwant\n%q", cmd.Commands, want)
+	}
+
+	// A template that is not in the catalogue is rejected
+	submission.View.ID = "V2"
+	submission.View.State.Values["repo-template"]["repo_template_select"].SelectedOption.Value = "cobol-app"
+	response, err = service.handleViewSubmission(context.Background(), &submission)
+	if err != nil || response == nil || response.Errors["repo-template"] == "" {
+		t.Errorf("Expected an error on the template select, got %+v, %v", response, err)
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// TransportPubSub receives payloads with Redis Pub/Sub (at most once)
+	TransportPubSub = "pubsub"
+	// TransportStreams receives payloads with Redis Streams consumer groups (at least once)
+	TransportStreams = "streams"
+	// StreamPayloadField is the stream entry field holding the JSON payload
+	StreamPayloadField = "payload"
+	// streamReadCount is the maximum number of entries read or claimed at once
+	streamReadCount = 10
+	// streamReadBlock is how long XREADGROUP blocks waiting for new entries
+	streamReadBlock = 5 * time.Second
+	// streamRetryDelay is how long to wait before retrying after a Redis error
+	streamRetryDelay = time.Second
+	// pubSubPingInterval is how long a subscription may be silent before it is pinged; a ping
+	// left unanswered for another interval marks the subscription as not live
+	pubSubPingInterval = 5 * time.Second
+	// pubSubRetryDelay is how long to wait before reconnecting a dropped subscription
+	pubSubRetryDelay = time.Second
+)
+
+// Message is a payload received from a Subscriber
+// Source is the channel or stream the payload was received from; Deliveries counts how many
+// times it has been delivered, or is 0 where the transport does not track it
+type Message struct {
+	Payload    string
+	Source     string
+	Deliveries int64
+	ack        func(ctx context.Context) error
+}
+
+// Ack marks the message as handled so it is not delivered again
+func (m *Message) Ack(ctx context.Context) error {
+	if m.ack == nil {
+		return nil
+	}
+	return m.ack(ctx)
+}
+
+// Subscriber delivers messages from a Redis channel or stream
+type Subscriber interface {
+	// Messages returns the channel messages are delivered on
+	Messages() <-chan *Message
+	// Close stops receiving messages
+	Close() error
+	// Subscribed reports whether the subscription is currently live
+	Subscribed() bool
+}
+
+// newSubscriber creates a Subscriber for name using the configured transport
+func newSubscriber(ctx context.Context, logger *Logger, redisClient *redis.Client, config *Config, name string) (Subscriber, error) {
+	switch config.Transport {
+	case TransportStreams:
+		return NewStreamSubscriber(ctx, logger, redisClient, name, config.RedisStreamGroup, config.RedisStreamConsumer, config.StreamClaimMinIdle)
+	default:
+		return NewPubSubSubscriber(ctx, redisClient, name, pubSubPingInterval)
+	}
+}
+
+// PubSubSubscriber receives messages published to a Redis channel
+// Messages published while the service is not subscribed are lost
+type PubSubSubscriber struct {
+	pubsub       *redis.PubSub
+	channel      string
+	pingInterval time.Duration
+	messages     chan *Message
+	subscribed   atomic.Bool
+}
+
+// NewPubSubSubscriber subscribes to channel and waits for the subscription to be confirmed
+// The subscription is pinged after pingInterval without traffic to check that it is still live
+func NewPubSubSubscriber(ctx context.Context, redisClient *redis.Client, channel string, pingInterval time.Duration) (*PubSubSubscriber, error) {
+	pubsub := redisClient.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", channel, err)
+	}
+
+	s := &PubSubSubscriber{
+		pubsub:       pubsub,
+		channel:      channel,
+		pingInterval: pingInterval,
+		messages:     make(chan *Message),
+	}
+	s.subscribed.Store(true)
+	go s.run()
+	return s, nil
+}
+
+// run receives from the subscription until it is closed
+// go-redis reconnects and resubscribes without telling its caller, so liveness is tracked
+// here: a dropped connection or an unanswered ping marks the subscription as not live, and
+// the confirmation after resubscribing, a pong or a message marks it live again
+func (s *PubSubSubscriber) run() {
+	defer close(s.messages)
+	defer s.subscribed.Store(false)
+
+	ctx := context.Background()
+	pingPending := false
+	for {
+		msg, err := s.pubsub.ReceiveTimeout(ctx, s.pingInterval)
+		var netErr net.Error
+		switch {
+		case errors.Is(err, redis.ErrClosed):
+			return
+		case errors.As(err, &netErr) && netErr.Timeout():
+			if pingPending {
+				s.subscribed.Store(false)
+			}
+			if err := s.pubsub.Ping(ctx); err != nil {
+				s.subscribed.Store(false)
+			}
+			pingPending = true
+			continue
+		case err != nil:
+			// The next receive reconnects and resubscribes
+			s.subscribed.Store(false)
+			pingPending = false
+			time.Sleep(pubSubRetryDelay)
+			continue
+		}
+
+		pingPending = false
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			s.subscribed.Store(msg.Kind == "subscribe")
+		case *redis.Pong:
+			s.subscribed.Store(true)
+		case *redis.Message:
+			s.subscribed.Store(true)
+			s.messages <- &Message{Payload: msg.Payload, Source: s.channel}
+		}
+	}
+}
+
+// Messages returns the channel messages are delivered on
+func (s *PubSubSubscriber) Messages() <-chan *Message {
+	return s.messages
+}
+
+// Close unsubscribes from the channel
+func (s *PubSubSubscriber) Close() error {
+	return s.pubsub.Close()
+}
+
+// Subscribed reports whether the channel subscription is live
+func (s *PubSubSubscriber) Subscribed() bool {
+	return s.subscribed.Load()
+}
+
+// StreamSubscriber reads entries from a Redis stream as a member of a consumer group
+// Entries are acknowledged with XACK once handled; entries left pending by a failed
+// handler or a crashed replica are reclaimed with XAUTOCLAIM after minIdle
+type StreamSubscriber struct {
+	logger   *Logger
+	client   *redis.Client
+	stream   string
+	group    string
+	consumer string
+	minIdle  time.Duration
+	messages chan *Message
+	cancel   context.CancelFunc
+	// readOK records whether the last read from the stream succeeded
+	readOK atomic.Bool
+}
+
+// NewStreamSubscriber joins group on stream, creating both if needed, and starts reading
+func NewStreamSubscriber(ctx context.Context, logger *Logger, redisClient *redis.Client, stream, group, consumer string, minIdle time.Duration) (*StreamSubscriber, error) {
+	// "$" only delivers entries added after the group is first created
+	err := redisClient.XGroupCreateMkStream(ctx, stream, group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, fmt.Errorf("failed to create consumer group %s on stream %s: %w", group, stream, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &StreamSubscriber{
+		logger:   logger,
+		client:   redisClient,
+		stream:   stream,
+		group:    group,
+		consumer: consumer,
+		minIdle:  minIdle,
+		messages: make(chan *Message),
+		cancel:   cancel,
+	}
+	s.readOK.Store(true)
+	go s.run(ctx)
+	return s, nil
+}
+
+func (s *StreamSubscriber) run(ctx context.Context) {
+	defer close(s.messages)
+	defer s.readOK.Store(false)
+
+	// Reclaim immediately so entries left behind by a previous run are not delayed
+	lastClaim := time.Time{}
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= s.minIdle {
+			s.reclaim(ctx)
+			lastClaim = time.Now()
+		}
+
+		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.consumer,
+			Streams:  []string{s.stream, ">"},
+			Count:    streamReadCount,
+			Block:    streamReadBlock,
+		}).Result()
+		s.readOK.Store(err == nil || errors.Is(err, redis.Nil))
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Error("Failed to read from stream", "stream", s.stream, "error", err)
+				sleepContext(ctx, streamRetryDelay)
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, entry := range stream.Messages {
+				if !s.deliver(ctx, entry, 1) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// reclaim takes over entries that have been pending for longer than minIdle
+func (s *StreamSubscriber) reclaim(ctx context.Context) {
+	start := "0-0"
+	for {
+		entries, next, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   s.stream,
+			Group:    s.group,
+			Consumer: s.consumer,
+			MinIdle:  s.minIdle,
+			Start:    start,
+			Count:    streamReadCount,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Error("Failed to reclaim pending entries", "stream", s.stream, "error", err)
+			}
+			return
+		}
+
+		var deliveries map[string]int64
+		if len(entries) > 0 {
+			s.logger.Info("Reclaimed pending entries", "stream", s.stream, "count", len(entries))
+			deliveries = s.deliveryCounts(ctx, entries)
+		}
+		for _, entry := range entries {
+			if !s.deliver(ctx, entry, deliveries[entry.ID]) {
+				return
+			}
+		}
+
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+// deliveryCounts looks up how many times each reclaimed entry has been delivered
+// XAUTOCLAIM counts the claim itself but does not return the count, so it is read back with XPENDING;
+// if that fails the counts are left out and the entries are retried without a limit this time
+func (s *StreamSubscriber) deliveryCounts(ctx context.Context, entries []redis.XMessage) map[string]int64 {
+	pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   s.stream,
+		Group:    s.group,
+		Start:    entries[0].ID,
+		End:      entries[len(entries)-1].ID,
+		Count:    int64(len(entries)),
+		Consumer: s.consumer,
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("Failed to read delivery counts", "stream", s.stream, "error", err)
+		}
+		return nil
+	}
+
+	counts := make(map[string]int64, len(pending))
+	for _, p := range pending {
+		counts[p.ID] = p.RetryCount
+	}
+	return counts
+}
+
+// deliver sends an entry to the message channel and reports whether the subscriber is still running
+func (s *StreamSubscriber) deliver(ctx context.Context, entry redis.XMessage, deliveries int64) bool {
+	payload, ok := entry.Values[StreamPayloadField].(string)
+	if !ok {
+		// There is nothing to hand to a handler, so acknowledge it rather than reclaim it forever
+		s.logger.Error("Stream entry has no payload field, discarding", "stream", s.stream, "entry_id", entry.ID, "field", StreamPayloadField)
+		if err := s.client.XAck(ctx, s.stream, s.group, entry.ID).Err(); err != nil {
+			s.logger.Error("Failed to acknowledge stream entry", "stream", s.stream, "entry_id", entry.ID, "error", err)
+		}
+		return true
+	}
+
+	id := entry.ID
+	msg := &Message{
+		Payload:    payload,
+		Source:     s.stream,
+		Deliveries: deliveries,
+		ack: func(ctx context.Context) error {
+			return s.client.XAck(ctx, s.stream, s.group, id).Err()
+		},
+	}
+
+	select {
+	case s.messages <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Messages returns the channel messages are delivered on
+func (s *StreamSubscriber) Messages() <-chan *Message {
+	return s.messages
+}
+
+// Subscribed reports whether the last read from the stream succeeded
+func (s *StreamSubscriber) Subscribed() bool {
+	return s.readOK.Load()
+}
+
+// Close stops reading from the stream; unacknowledged entries stay pending for reclaim
+func (s *StreamSubscriber) Close() error {
+	s.cancel()
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestMessageAck tests that acknowledging calls the transport's ack function when present
+func TestMessageAck(t *testing.T) {
+	ctx := context.Background()
+
+	// Pub/Sub messages have nothing to acknowledge
+	if err := (&Message{Payload: "{}"}).Ack(ctx); err != nil {
+		t.Errorf("Expected no error acknowledging a Pub/Sub message, got %v", err)
+	}
+
+	acked := 0
+	msg := &Message{Payload: "{}", ack: func(ctx context.Context) error {
+		acked++
+		return nil
+	}}
+	if err := msg.Ack(ctx); err != nil || acked != 1 {
+		t.Errorf("Expected ack to be called once without error, got %d calls, error %v", acked, err)
+	}
+
+	failing := &Message{ack: func(ctx context.Context) error { return errors.New("XACK failed") }}
+	if err := failing.Ack(ctx); err == nil {
+		t.Error("Expected the ack error to be returned")
+	}
+}
+
+// TestSleepContext tests that sleeping stops early when the context is cancelled
+func TestSleepContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	sleepContext(ctx, time.Minute)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected sleepContext to return immediately, took %v", elapsed)
+	}
+}
+
+// receiveMessage waits for the next message from sub
+func receiveMessage(t *testing.T, sub Subscriber) *Message {
+	t.Helper()
+	select {
+	case msg, ok := <-sub.Messages():
+		if !ok {
+			t.Fatal("Subscriber closed unexpectedly")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a message")
+	}
+	return nil
+}
+
+// TestStreamSubscriber tests delivery, reclaim of unacknowledged entries and acknowledgement
+func TestStreamSubscriber(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+	ctx := context.Background()
+	minIdle := 200 * time.Millisecond
+
+	sub, err := NewStreamSubscriber(ctx, NewLogger("error"), redisClient, "slack-commands", "slashviberepo", "replica-1", minIdle)
+	if err != nil {
+		t.Fatalf("NewStreamSubscriber() failed: %v", err)
+	}
+	defer sub.Close()
+
+	// Joining a group that already exists is not an error
+	other, err := NewStreamSubscriber(ctx, NewLogger("error"), redisClient, "slack-commands", "slashviberepo", "replica-2", time.Hour)
+	if err != nil {
+		t.Fatalf("Expected to join the existing group, got %v", err)
+	}
+	other.Close()
+
+	add := func(payload string) string {
+		t.Helper()
+		id, err := redisClient.XAdd(ctx, &redis.XAddArgs{Stream: "slack-commands", Values: map[string]interface{}{StreamPayloadField: payload}}).Result()
+		if err != nil {
+			t.Fatalf("XADD failed: %v", err)
+		}
+		return id
+	}
+
+	add(`{"command":"/new-repo"}`)
+	msg := receiveMessage(t, sub)
+	if msg.Payload != `{"command":"/new-repo"}` || msg.Source != "slack-commands" || msg.Deliveries != 1 {
+		t.Fatalf("Unexpected message: %+v", msg)
+	}
+	if !sub.Subscribed() {
+		t.Error("Expected the subscriber to report a live read")
+	}
+
+	// Left unacknowledged, the entry is delivered again once it has been idle for minIdle
+	// A second entry wakes the blocking read so the reclaim runs straight after it
+	time.Sleep(minIdle + 50*time.Millisecond)
+	add(`{"command":"/second"}`)
+	second := receiveMessage(t, sub)
+	if second.Payload != `{"command":"/second"}` {
+		t.Fatalf("Expected the new entry first, got %+v", second)
+	}
+	reclaimed := receiveMessage(t, sub)
+	if reclaimed.Payload != msg.Payload || reclaimed.Deliveries != 2 {
+		t.Fatalf("Expected the unacknowledged entry to be reclaimed, got %+v", reclaimed)
+	}
+
+	for _, m := range []*Message{second, reclaimed} {
+		if err := m.Ack(ctx); err != nil {
+			t.Fatalf("Ack() failed: %v", err)
+		}
+	}
+	pending, err := redisClient.XPending(ctx, "slack-commands", "slashviberepo").Result()
+	if err != nil {
+		t.Fatalf("XPENDING failed: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("Expected nothing pending after acknowledging, got %+v", pending)
+	}
+}
+
+// TestPubSubSubscriberReconnect tests that readiness follows the connection through an outage
+func TestPubSubSubscriberReconnect(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+	ctx := context.Background()
+
+	sub, err := NewPubSubSubscriber(ctx, redisClient, "slack-commands", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewPubSubSubscriber() failed: %v", err)
+	}
+	defer sub.Close()
+
+	// Pings keep a quiet subscription live
+	time.Sleep(200 * time.Millisecond)
+	if !sub.Subscribed() {
+		t.Fatal("Expected a quiet subscription to stay live")
+	}
+
+	server.Close()
+	waitFor(t, "the subscription to be reported down", func() bool { return !sub.Subscribed() })
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("Failed to restart Redis: %v", err)
+	}
+	waitFor(t, "the subscription to be restored", sub.Subscribed)
+
+	server.Publish("slack-commands", `{"command":"/new-repo"}`)
+	if msg := receiveMessage(t, sub); msg.Payload != `{"command":"/new-repo"}` || msg.Source != "slack-commands" {
+		t.Errorf("Unexpected message after reconnecting: %+v", msg)
+	}
+}
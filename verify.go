@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/subtle"
+	"strings"
+)
+
+// Reasons recorded on rejectedPayloadsTotal
+const (
+	RejectVerificationToken = "verification_token"
+	RejectTeamID            = "team_id"
+	RejectAppID             = "app_id"
+)
+
+// PayloadVerifier checks that a payload came from the expected Slack workspace and app
+// Anyone who can publish to the Redis channels can forge a payload, so the relay's
+// verification token and the team and app IDs are checked against the configuration
+type PayloadVerifier struct {
+	token       string
+	allowedTeam map[string]bool
+	allowedApp  map[string]bool
+}
+
+// NewPayloadVerifier creates a verifier from the configured token and allow-lists
+// An empty token or allow-list disables that check
+func NewPayloadVerifier(token string, teamIDs, appIDs []string) *PayloadVerifier {
+	return &PayloadVerifier{
+		token:       token,
+		allowedTeam: toSet(teamIDs),
+		allowedApp:  toSet(appIDs),
+	}
+}
+
+// Verify returns the reason a payload is rejected, or "" if it is accepted
+func (v *PayloadVerifier) Verify(token, teamID, appID string) string {
+	if v == nil {
+		return ""
+	}
+	if v.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(v.token)) != 1 {
+		return RejectVerificationToken
+	}
+	if len(v.allowedTeam) > 0 && !v.allowedTeam[teamID] {
+		return RejectTeamID
+	}
+	if len(v.allowedApp) > 0 && !v.allowedApp[appID] {
+		return RejectAppID
+	}
+	return ""
+}
+
+// splitList splits a comma-separated environment variable, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
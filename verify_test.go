@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/slack-go/slack"
+)
+
+// TestPayloadVerifierVerify tests each check and that unconfigured checks are skipped
+func TestPayloadVerifierVerify(t *testing.T) {
+	strict := NewPayloadVerifier("secret", []string{"T1", "T2"}, []string{"A1"})
+
+	tests := []struct {
+		name     string
+		verifier *PayloadVerifier
+		token    string
+		teamID   string
+		appID    string
+		want     string
+	}{
+		{"Accepted", strict, "secret", "T2", "A1", ""},
+		{"WrongToken", strict, "guess", "T1", "A1", RejectVerificationToken},
+		{"MissingToken", strict, "", "T1", "A1", RejectVerificationToken},
+		{"WrongTeam", strict, "secret", "T3", "A1", RejectTeamID},
+		{"WrongApp", strict, "secret", "T1", "A2", RejectAppID},
+		{"NothingConfigured", NewPayloadVerifier("", nil, nil), "", "", "", ""},
+		{"TeamsOnly", NewPayloadVerifier("", []string{"T1"}, nil), "", "T1", "anything", ""},
+		{"NilVerifier", nil, "", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.verifier.Verify(tt.token, tt.teamID, tt.appID); got != tt.want {
+				t.Errorf("Verify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSplitList tests that comma-separated lists are trimmed and empty entries dropped
+func TestSplitList(t *testing.T) {
+	if got := splitList(" T1, T2,,T3 "); !reflect.DeepEqual(got, []string{"T1", "T2", "T3"}) {
+		t.Errorf("splitList() = %v", got)
+	}
+	if got := splitList(""); len(got) != 0 {
+		t.Errorf("Expected no entries for an empty value, got %v", got)
+	}
+}
+
+// TestCommandRouterRejectsUnverifiedPayloads tests that rejected payloads never reach a handler
+func TestCommandRouterRejectsUnverifiedPayloads(t *testing.T) {
+	responder := &fakeViewResponder{}
+	router := NewCommandRouter(NewLogger("error"), responder, NewPayloadVerifier("secret", []string{"T1"}, nil))
+
+	var handled []string
+	err := router.Register(&SlashCommand{
+		Name:         "/alpha",
+		CallbackIDs:  []string{"alpha_modal"},
+		ErrorBlockID: "repo-name",
+		HandleCommand: func(ctx context.Context, cmd *SlashCommandPayload) {
+			handled = append(handled, "command:"+cmd.TeamID)
+		},
+		HandleViewSubmission: func(ctx context.Context, submission *ViewSubmissionPayload) (*slack.ViewSubmissionResponse, error) {
+			handled = append(handled, "view:"+submission.Team.ID)
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register command: %v", err)
+	}
+
+	rejectedCommands := rejectedPayloadsTotal.WithLabelValues("slash_command", RejectTeamID)
+	rejectedViews := rejectedPayloadsTotal.WithLabelValues("view_submission", RejectVerificationToken)
+	commandsBefore, viewsBefore := testutil.ToFloat64(rejectedCommands), testutil.ToFloat64(rejectedViews)
+
+	ctx := context.Background()
+	router.HandleMessage(ctx, `{"command":"/alpha","token":"secret","team_id":"T1"}`)
+	router.HandleMessage(ctx, `{"command":"/alpha","token":"secret","team_id":"T9"}`)
+	router.HandleViewSubmission(ctx, `{"token":"secret","team":{"id":"T1"},"view":{"id":"V1","callback_id":"alpha_modal"}}`)
+	router.HandleViewSubmission(ctx, `{"token":"forged","team":{"id":"T1"},"view":{"id":"V2","callback_id":"alpha_modal"}}`)
+
+	if !reflect.DeepEqual(handled, []string{"command:T1", "view:T1"}) {
+		t.Errorf("Unexpected payloads handled: %v", handled)
+	}
+	if _, ok := responder.responses["V2"]; ok {
+		t.Error("Expected no view response for a rejected submission")
+	}
+	if got := testutil.ToFloat64(rejectedCommands) - commandsBefore; got != 1 {
+		t.Errorf("Expected 1 rejected slash command, got %v", got)
+	}
+	if got := testutil.ToFloat64(rejectedViews) - viewsBefore; got != 1 {
+		t.Errorf("Expected 1 rejected view submission, got %v", got)
+	}
+}